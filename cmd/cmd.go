@@ -1,25 +1,91 @@
 package cmd
 
 import (
+	"account-management/config"
+	"account-management/db"
+	"account-management/model"
+	"account-management/money"
+	re "account-management/redis"
 	"account-management/router"
 	"account-management/service"
+	"account-management/statement"
+	"account-management/utils.go"
+	"fmt"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 )
 
+// messageChannels are the Redis streams transaction requests are queued on.
 var messageChannels = []string{"request"}
 
+// cfg is loaded before any command runs, see loadConfig.
+var cfg *config.Config
+
 // serveCmd represents the serve command
 var RootCmd = &cobra.Command{
-	Use:   "",
-	Short: "Api server",
+	Use:               "",
+	Short:             "Api server",
+	PersistentPreRunE: loadConfig,
+}
+
+// loadConfig loads and validates the configuration shared by every command, then applies the
+// process wide settings (currency, balance rules, limits, fees, interest rates, risk rules, webhook retries, jwt-token signing).
+func loadConfig(cmd *cobra.Command, args []string) error {
+	var err error
+	cfg, err = config.Load(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	money.DefaultCurrency = cfg.Account.Currency
+
+	initialBalance, _ := cfg.InitialBalance()
+	minimumBalance, _ := cfg.MinimumBalance()
+	model.SetBalanceRules(initialBalance, minimumBalance)
+	model.SetRequireVerification(cfg.Account.RequireVerification)
+
+	tierLimits := map[string]map[string]model.Limits{}
+	for tier, types := range cfg.Limits {
+		tierLimits[tier] = map[string]model.Limits{}
+		for txType, limit := range types {
+			perTransaction, daily, monthly, _ := limit.Parse(cfg.Account.Currency)
+			tierLimits[tier][txType] = model.Limits{PerTransaction: perTransaction, Daily: daily, Monthly: monthly}
+		}
+	}
+	model.SetTierLimits(tierLimits)
+	model.SetFeeSchedule(cfg.Fees)
+
+	products := map[string][]model.RateBand{}
+	for product, bands := range cfg.Interest.Products {
+		for _, band := range bands {
+			from, rate, _ := band.Parse(cfg.Account.Currency)
+			products[product] = append(products[product], model.RateBand{From: from, Rate: rate})
+		}
+	}
+	overdraftRate, _ := config.ParseRate(cfg.Interest.OverdraftRate)
+	model.SetInterestRates(products, overdraftRate, cfg.Interest.DayCount)
+
+	riskRules, _ := cfg.Risk.Rules(cfg.Account.Currency)
+	model.SetRiskRules(riskRules)
+	model.SetWebhookPolicy(model.WebhookPolicy{
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		RetryBase:    cfg.Webhooks.RetryBase,
+		RetryMax:     cfg.Webhooks.RetryMax,
+		DisableAfter: cfg.Webhooks.DisableAfter,
+	})
+
+	utils.SetTokenConfig(cfg.JWT.Secret, cfg.JWT.TokenLifespan, cfg.JWT.RefreshTokenLifespan, cfg.JWT.Issuer)
+	return nil
 }
 
 var apiCmd = &cobra.Command{
 	Use:   "api",
 	Short: "Api server",
 	Run: func(cmd *cobra.Command, args []string) {
-		api := router.InitAPIServer(messageChannels)
+		api := router.InitAPIServer(cfg, messageChannels)
 		api.Start()
 	},
 }
@@ -30,16 +96,315 @@ var queueCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		useWorker, _ := cmd.Flags().GetBool("useWorker")
 		numWorkers, _ := cmd.Flags().GetInt("numWorker")
+		consumerName, _ := cmd.Flags().GetString("consumerName")
+		reclaimAfter, _ := cmd.Flags().GetDuration("reclaimAfter")
 
-		taskQueue := service.NewTaskQueue(useWorker, numWorkers, messageChannels)
+		taskQueue := service.NewTaskQueue(cfg, useWorker, numWorkers, consumerName, reclaimAfter, messageChannels)
 		taskQueue.Start()
 	},
 }
 
+var schedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Run the standing orders when they are due and expire holds",
+	Run: func(cmd *cobra.Command, args []string) {
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		scheduler := service.NewScheduler(cfg, interval, messageChannels)
+		scheduler.Start()
+	},
+}
+
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Send the webhook deliveries to their endpoints and retry the failed ones",
+	Run: func(cmd *cobra.Command, args []string) {
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		dispatcher := service.NewWebhookDispatcher(cfg, interval)
+		dispatcher.Start()
+	},
+}
+
+var ledgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Ledger maintenance",
+}
+
+var ledgerVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that every journal entry is balanced and balances match their postings",
+	Run: func(cmd *cobra.Command, args []string) {
+		database := db.InitDB(cfg.Database)
+		ledgerModel := model.NewLedgerModel(database, model.NewAccountModel(database))
+
+		problems, err := ledgerModel.Verify()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+			os.Exit(1)
+		}
+
+		fmt.Println("Ledger is balanced !")
+	},
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit log maintenance",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that no audit entry was edited or deleted",
+	Run: func(cmd *cobra.Command, args []string) {
+		head, _ := cmd.Flags().GetString("head")
+
+		database := db.InitDB(cfg.Database)
+		auditModel := model.NewAuditModel(database)
+
+		problems, last, err := auditModel.Verify()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		// entries deleted from the end leave a valid chain, only a head kept earlier reveals them
+		if head != "" {
+			found, err := auditModel.HasHash(head)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("entry with hash %s is missing : the end of the log was deleted", head))
+			}
+		}
+
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+			os.Exit(1)
+		}
+
+		if last == nil {
+			fmt.Println("Audit log is empty !")
+			return
+		}
+		fmt.Printf("Audit log is intact ! %d entries, head %s\n", last.Seq, last.Hash)
+	},
+}
+
+var interestCmd = &cobra.Command{
+	Use:   "interest",
+	Short: "Interest accrual and capitalisation",
+}
+
+var interestRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Accrue the interest of a day, and pay the month's interest on its last day",
+	Run: func(cmd *cobra.Command, args []string) {
+		dateFlag, _ := cmd.Flags().GetString("date")
+
+		date := time.Now().UTC().AddDate(0, 0, -1)
+		if dateFlag != "" {
+			var err error
+			date, err = time.Parse("2006-01-02", dateFlag)
+			if err != nil {
+				fmt.Println("date must be a date (2006-01-02)")
+				os.Exit(1)
+			}
+		}
+
+		if !model.Day(date).Before(model.Day(time.Now())) {
+			fmt.Println("date must be a past day, its balances must be final")
+			os.Exit(1)
+		}
+
+		interestRun := service.NewInterestRun(cfg, messageChannels)
+		if err := interestRun.Run(date); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Account maintenance",
+}
+
+var accountSetRoleCmd = &cobra.Command{
+	Use:   "set-role",
+	Short: "Give a role (customer, support, analyst, admin, auditor) to a user, e.g. to create the first admin",
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+		role, _ := cmd.Flags().GetString("role")
+
+		database := db.InitDB(cfg.Database)
+		redisClient := re.InitRedisClient(cfg.Redis)
+		userModel := model.NewUserModel(database)
+
+		userId, err := userModel.GetUserIdByUserName(username)
+		if err != nil || userId == "" {
+			fmt.Printf("user %s doesn't exist\n", username)
+			os.Exit(1)
+		}
+
+		// audited and revoked like a change made through the api, the system being the actor
+		err = database.Transaction(func(tx *gorm.DB) error {
+			userModel := model.NewUserModel(tx)
+			previous, err := userModel.GetUserRole(userId)
+			if err != nil {
+				return err
+			}
+			err = userModel.SetUserRole(userId, role)
+			if err != nil {
+				return err
+			}
+			// tokens carry the role, so the ones already issued must not outlive the change
+			err = re.RevokeUser(redisClient, userId, utils.TokenLifespan())
+			if err != nil {
+				return err
+			}
+			return model.NewAuditModel(tx).Append(&model.AuditEntry{
+				Event:      model.AuditUserRole,
+				TargetType: "user",
+				TargetId:   userId,
+				Before:     model.AuditValue(previous),
+				After:      model.AuditValue(role),
+				Detail:     "command line",
+			}, tx)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s is now %s, its tokens were revoked\n", username, role)
+	},
+}
+
+var statementCmd = &cobra.Command{
+	Use:   "statement",
+	Short: "Write the statement of an account for a period (csv, ofx or camt053)",
+	Run: func(cmd *cobra.Command, args []string) {
+		accountId, _ := cmd.Flags().GetString("account")
+		username, _ := cmd.Flags().GetString("username")
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+
+		if err := statement.Validate(format); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		from, err := time.Parse("2006-01-02", fromFlag)
+		if err != nil {
+			fmt.Println("from must be a date (2006-01-02)")
+			os.Exit(1)
+		}
+		to, err := time.Parse("2006-01-02", toFlag)
+		if err != nil {
+			fmt.Println("to must be a date (2006-01-02)")
+			os.Exit(1)
+		}
+
+		database := db.InitDB(cfg.Database)
+		accountModel := model.NewAccountModel(database)
+		ledgerModel := model.NewLedgerModel(database, accountModel)
+
+		if accountId == "" {
+			accountId, err = accountModel.GetAccountIdByUserName(username)
+			if err != nil || accountId == "" {
+				fmt.Printf("account %s doesn't exist\n", username)
+				os.Exit(1)
+			}
+		}
+
+		// to is the last day of the statement
+		s, err := ledgerModel.Statement(accountId, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if out == "" {
+			out = statement.FileName(s, format)
+		}
+		file, err := os.Create(out)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer file.Close()
+
+		if err := statement.Render(file, s, format); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Statement of %s written to %s (%d entries)\n", accountId, out, len(s.Lines))
+	},
+}
+
+func defaultConsumerName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "queue"
+	}
+	return hostname
+}
+
 func init() {
+	config.RegisterFlags(RootCmd.PersistentFlags())
 
 	queueCmd.Flags().Bool("useWorker", false, "use workers for concurrent processing")
 	queueCmd.Flags().Int("numWorker", 1, "number of workers for concurrent processing")
+	queueCmd.Flags().String("consumerName", defaultConsumerName(), "stable consumer name, reuse it after a restart to resume pending messages")
+	queueCmd.Flags().Duration("reclaimAfter", time.Minute, "take over messages left unacknowledged by other consumers for this long")
 	RootCmd.AddCommand(apiCmd)
 	RootCmd.AddCommand(queueCmd)
+
+	schedulerCmd.Flags().Duration("interval", time.Minute, "how often due standing orders are looked for")
+	RootCmd.AddCommand(schedulerCmd)
+
+	webhooksCmd.Flags().Duration("interval", 5*time.Second, "how often due deliveries are looked for")
+	RootCmd.AddCommand(webhooksCmd)
+
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+	RootCmd.AddCommand(ledgerCmd)
+
+	auditVerifyCmd.Flags().String("head", "", "hash of the last entry given by an earlier run, to detect entries deleted since from the end of the log")
+	auditCmd.AddCommand(auditVerifyCmd)
+	RootCmd.AddCommand(auditCmd)
+
+	interestRunCmd.Flags().String("date", "", "day to accrue (2006-01-02), yesterday by default")
+	interestCmd.AddCommand(interestRunCmd)
+	RootCmd.AddCommand(interestCmd)
+
+	accountSetRoleCmd.Flags().String("username", "", "username of the user")
+	accountSetRoleCmd.Flags().String("role", model.RoleAdmin, "role to give")
+	accountSetRoleCmd.MarkFlagRequired("username")
+	accountCmd.AddCommand(accountSetRoleCmd)
+	RootCmd.AddCommand(accountCmd)
+
+	statementCmd.Flags().String("account", "", "id of the account")
+	statementCmd.Flags().String("username", "", "username whose default account is used, when --account isn't given")
+	statementCmd.Flags().String("from", "", "first day of the statement (2006-01-02)")
+	statementCmd.Flags().String("to", "", "last day of the statement (2006-01-02)")
+	statementCmd.Flags().String("format", statement.FormatCSV, "csv, ofx or camt053")
+	statementCmd.Flags().String("out", "", "output file (default statement-<account>-<from>-<to>.<ext>)")
+	statementCmd.MarkFlagRequired("from")
+	statementCmd.MarkFlagRequired("to")
+	RootCmd.AddCommand(statementCmd)
 }
@@ -0,0 +1,511 @@
+package config
+
+import (
+	"account-management/fee"
+	"account-management/money"
+	"account-management/risk"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix prefixes every environment variable, e.g. database.host is read from BAM_DATABASE_HOST.
+const envPrefix = "BAM_"
+
+const defaultConfigFile = "config.yaml"
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Redis    RedisConfig    `yaml:"redis"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Account  AccountConfig  `yaml:"account"`
+	// Limits holds the default transaction limits of each account tier, by transaction type.
+	Limits map[string]map[string]LimitConfig `yaml:"limits"`
+	// Fees are the rules pricing transactions, the first rule matching a transaction applies.
+	Fees     fee.Schedule   `yaml:"fees"`
+	Interest InterestConfig `yaml:"interest"`
+	// Risk enables the rules evaluated before transactions are posted.
+	Risk     risk.Config   `yaml:"risk"`
+	Webhooks WebhookConfig `yaml:"webhooks"`
+}
+
+type ServerConfig struct {
+	Port int `yaml:"port"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type JWTConfig struct {
+	Secret               string        `yaml:"secret"`
+	TokenLifespan        time.Duration `yaml:"token_lifespan"`
+	RefreshTokenLifespan time.Duration `yaml:"refresh_token_lifespan"`
+	Issuer               string        `yaml:"issuer"`
+}
+
+// AccountConfig holds the business parameters applied to accounts. Amounts are decimal strings
+// in Currency, e.g. "50000" VND or "500.00" USD.
+type AccountConfig struct {
+	Currency       string `yaml:"currency"`
+	InitialBalance string `yaml:"initial_balance"`
+	MinimumBalance string `yaml:"minimum_balance"`
+	// RequireVerification makes new accounts start PENDING_VERIFICATION until an admin activates them.
+	RequireVerification bool `yaml:"require_verification"`
+}
+
+// LimitConfig caps the amounts of one transaction type. Amounts are decimal strings in
+// account.currency ; a blank amount means no limit.
+type LimitConfig struct {
+	PerTransaction string `yaml:"per_transaction"`
+	Daily          string `yaml:"daily"`
+	Monthly        string `yaml:"monthly"`
+}
+
+// Parse returns the per transaction, daily and monthly limits, nil when unlimited.
+func (l LimitConfig) Parse(currency string) (perTransaction, daily, monthly *money.Money, err error) {
+	parse := func(name, amount string) (*money.Money, error) {
+		if amount == "" {
+			return nil, nil
+		}
+		m, err := money.Parse(amount, currency)
+		if err != nil {
+			return nil, fmt.Errorf("%s : %v", name, err)
+		}
+		if !m.IsPositive() {
+			return nil, fmt.Errorf("%s must be greater than 0", name)
+		}
+		return &m, nil
+	}
+
+	if perTransaction, err = parse("per_transaction", l.PerTransaction); err != nil {
+		return
+	}
+	if daily, err = parse("daily", l.Daily); err != nil {
+		return
+	}
+	monthly, err = parse("monthly", l.Monthly)
+	return
+}
+
+// WebhookConfig tells the webhook dispatcher how long to wait for endpoints and how to retry.
+// Attempt n of a delivery waits retry_base * 2^(n-1), at most retry_max.
+type WebhookConfig struct {
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	RetryBase   time.Duration `yaml:"retry_base"`
+	RetryMax    time.Duration `yaml:"retry_max"`
+	// DisableAfter is the number of failed attempts in a row after which a subscription is disabled.
+	DisableAfter int `yaml:"disable_after"`
+}
+
+// InterestConfig holds the interest rate table of each account product.
+type InterestConfig struct {
+	// DayCount is the number of days in a year, daily interest is balance * rate / DayCount.
+	DayCount int                         `yaml:"day_count"`
+	Products map[string][]RateBandConfig `yaml:"products"`
+	// OverdraftRate is the annual rate in percent charged on negative balances.
+	OverdraftRate string `yaml:"overdraft_rate"`
+}
+
+// RateBandConfig applies an annual rate in percent to the part of a balance above From, up to
+// the From of the next band. From is a decimal amount in account.currency.
+type RateBandConfig struct {
+	From string `yaml:"from"`
+	Rate string `yaml:"rate"`
+}
+
+func (b RateBandConfig) Parse(currency string) (money.Money, *big.Rat, error) {
+	from, err := money.Parse(b.From, currency)
+	if err != nil {
+		return money.Money{}, nil, fmt.Errorf("from : %v", err)
+	}
+	rate, err := ParseRate(b.Rate)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	return from, rate, nil
+}
+
+// ParseRate reads a rate in percent such as "4.5".
+func ParseRate(rate string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() < 0 || strings.ContainsAny(rate, "/eE") {
+		return nil, fmt.Errorf("rate must be a positive decimal number : %s", rate)
+	}
+	return r, nil
+}
+
+// Default returns the settings used for local development.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port: 8080,
+		},
+		Database: DatabaseConfig{
+			Host:    "127.0.0.1",
+			Port:    5432,
+			User:    "hppoc",
+			Name:    "account-management",
+			SSLMode: "disable",
+		},
+		Redis: RedisConfig{
+			Addr: "127.0.0.1:6379",
+		},
+		JWT: JWTConfig{
+			TokenLifespan:        15 * time.Minute,
+			RefreshTokenLifespan: 30 * 24 * time.Hour,
+			Issuer:               "account-management",
+		},
+		Account: AccountConfig{
+			Currency:       "VND",
+			InitialBalance: "50000",
+			MinimumBalance: "50000",
+		},
+		Limits: map[string]map[string]LimitConfig{
+			"standard": {
+				"Withdraw": {PerTransaction: "20000000", Daily: "50000000", Monthly: "300000000"},
+				"Transfer": {PerTransaction: "50000000", Daily: "100000000", Monthly: "1000000000"},
+			},
+			"premium": {
+				"Withdraw": {PerTransaction: "100000000", Daily: "200000000", Monthly: "2000000000"},
+				"Transfer": {PerTransaction: "500000000", Daily: "1000000000", Monthly: "10000000000"},
+			},
+		},
+		Interest: InterestConfig{
+			DayCount: 365,
+			Products: map[string][]RateBandConfig{
+				"current": {{From: "0", Rate: "0.1"}},
+				"savings": {{From: "0", Rate: "3"}, {From: "100000000", Rate: "4"}},
+			},
+			OverdraftRate: "15",
+		},
+		Webhooks: WebhookConfig{
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			RetryBase:    30 * time.Second,
+			RetryMax:     6 * time.Hour,
+			DisableAfter: 20,
+		},
+	}
+}
+
+// setting binds one configuration value to its environment variable and command line flag.
+type setting struct {
+	key   string
+	flag  string
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, value string) error
+}
+
+func stringSetting(key, flag, usage string, field func(c *Config) *string) setting {
+	return setting{
+		key:   key,
+		flag:  flag,
+		usage: usage,
+		get:   func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func intSetting(key, flag, usage string, field func(c *Config) *int) setting {
+	return setting{
+		key:   key,
+		flag:  flag,
+		usage: usage,
+		get:   func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer : %v", key, err)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func boolSetting(key, flag, usage string, field func(c *Config) *bool) setting {
+	return setting{
+		key:   key,
+		flag:  flag,
+		usage: usage,
+		get:   func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false : %v", key, err)
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func durationSetting(key, flag, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{
+		key:   key,
+		flag:  flag,
+		usage: usage,
+		get:   func(c *Config) string { return field(c).String() },
+		set: func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration : %v", key, err)
+			}
+			*field(c) = d
+			return nil
+		},
+	}
+}
+
+var settings = []setting{
+	intSetting("server.port", "port", "port of the api server", func(c *Config) *int { return &c.Server.Port }),
+
+	stringSetting("database.host", "dbHost", "postgres host", func(c *Config) *string { return &c.Database.Host }),
+	intSetting("database.port", "dbPort", "postgres port", func(c *Config) *int { return &c.Database.Port }),
+	stringSetting("database.user", "dbUser", "postgres user", func(c *Config) *string { return &c.Database.User }),
+	stringSetting("database.password", "dbPassword", "postgres password", func(c *Config) *string { return &c.Database.Password }),
+	stringSetting("database.name", "dbName", "postgres database name", func(c *Config) *string { return &c.Database.Name }),
+	stringSetting("database.sslmode", "dbSSLMode", "postgres sslmode", func(c *Config) *string { return &c.Database.SSLMode }),
+
+	stringSetting("redis.addr", "redisAddr", "redis address (host:port)", func(c *Config) *string { return &c.Redis.Addr }),
+	stringSetting("redis.password", "redisPassword", "redis password", func(c *Config) *string { return &c.Redis.Password }),
+	intSetting("redis.db", "redisDB", "redis database number", func(c *Config) *int { return &c.Redis.DB }),
+
+	stringSetting("jwt.secret", "jwtSecret", "secret used to sign jwt-tokens", func(c *Config) *string { return &c.JWT.Secret }),
+	durationSetting("jwt.token_lifespan", "tokenLifespan", "lifespan of access tokens", func(c *Config) *time.Duration { return &c.JWT.TokenLifespan }),
+	durationSetting("jwt.refresh_token_lifespan", "refreshTokenLifespan", "lifespan of refresh tokens", func(c *Config) *time.Duration { return &c.JWT.RefreshTokenLifespan }),
+	stringSetting("jwt.issuer", "jwtIssuer", "issuer (iss claim) of jwt-tokens", func(c *Config) *string { return &c.JWT.Issuer }),
+
+	stringSetting("account.currency", "currency", "currency of new accounts", func(c *Config) *string { return &c.Account.Currency }),
+	stringSetting("account.initial_balance", "initialBalance", "balance granted to new accounts", func(c *Config) *string { return &c.Account.InitialBalance }),
+	stringSetting("account.minimum_balance", "minimumBalance", "legacy floor : balance the accounts opened before per-account floors must keep after a withdrawal or transfer", func(c *Config) *string { return &c.Account.MinimumBalance }),
+	boolSetting("account.require_verification", "requireVerification", "new accounts wait in PENDING_VERIFICATION until an admin activates them", func(c *Config) *bool { return &c.Account.RequireVerification }),
+
+	durationSetting("webhooks.timeout", "webhookTimeout", "how long the webhook dispatcher waits for an endpoint", func(c *Config) *time.Duration { return &c.Webhooks.Timeout }),
+	intSetting("webhooks.max_attempts", "webhookMaxAttempts", "attempts of a webhook delivery before it fails", func(c *Config) *int { return &c.Webhooks.MaxAttempts }),
+
+	stringSetting("interest.overdraft_rate", "overdraftRate", "annual rate in percent charged on negative balances", func(c *Config) *string { return &c.Interest.OverdraftRate }),
+}
+
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// RegisterFlags adds the --config flag and one flag per setting to flags.
+func RegisterFlags(flags *pflag.FlagSet) {
+	defaults := Default()
+
+	flags.String("config", "", fmt.Sprintf("path of the YAML config file (default %s if present, env %sCONFIG)", defaultConfigFile, envPrefix))
+	for _, s := range settings {
+		flags.String(s.flag, s.get(defaults), fmt.Sprintf("%s (env %s)", s.usage, envName(s.key)))
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence : defaults, the YAML
+// file, BAM_* environment variables and the command line flags that were explicitly set.
+func Load(flags *pflag.FlagSet) (*Config, error) {
+	cfg := Default()
+
+	path, _ := flags.GetString("config")
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			path = defaultConfigFile
+		}
+	}
+
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file : %v", err)
+		}
+
+		// tables of the file (limits, interest rates) replace the default ones instead of being
+		// merged into them
+		defaultLimits, defaultProducts := cfg.Limits, cfg.Interest.Products
+		cfg.Limits, cfg.Interest.Products = nil, nil
+		if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s : %v", path, err)
+		}
+		if cfg.Limits == nil {
+			cfg.Limits = defaultLimits
+		}
+		if cfg.Interest.Products == nil {
+			cfg.Interest.Products = defaultProducts
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(envName(s.key)); ok {
+			if err := s.set(cfg, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, s := range settings {
+		flag := flags.Lookup(s.flag)
+		if flag != nil && flag.Changed {
+			if err := s.set(cfg, flag.Value.String()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		problems = append(problems, "server.port must be between 1 and 65535")
+	}
+
+	if c.Database.Host == "" {
+		problems = append(problems, "database.host must not be blank")
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		problems = append(problems, "database.port must be between 1 and 65535")
+	}
+	if c.Database.User == "" {
+		problems = append(problems, "database.user must not be blank")
+	}
+	if c.Database.Name == "" {
+		problems = append(problems, "database.name must not be blank")
+	}
+
+	if c.Redis.Addr == "" {
+		problems = append(problems, "redis.addr must not be blank")
+	}
+	if c.Redis.DB < 0 {
+		problems = append(problems, "redis.db must not be negative")
+	}
+
+	if len(c.JWT.Secret) < 16 {
+		problems = append(problems, "jwt.secret must be at least 16 characters long")
+	}
+	if c.JWT.TokenLifespan <= 0 {
+		problems = append(problems, "jwt.token_lifespan must be positive")
+	}
+	if c.JWT.RefreshTokenLifespan <= c.JWT.TokenLifespan {
+		problems = append(problems, "jwt.refresh_token_lifespan must be longer than jwt.token_lifespan")
+	}
+	if c.JWT.Issuer == "" {
+		problems = append(problems, "jwt.issuer must not be blank")
+	}
+
+	if _, err := money.Exponent(c.Account.Currency); err != nil {
+		problems = append(problems, fmt.Sprintf("account.currency : %v", err))
+	} else {
+		if _, err := c.InitialBalance(); err != nil {
+			problems = append(problems, fmt.Sprintf("account.initial_balance : %v", err))
+		}
+		if _, err := c.MinimumBalance(); err != nil {
+			problems = append(problems, fmt.Sprintf("account.minimum_balance : %v", err))
+		}
+		for tier, types := range c.Limits {
+			for txType, limit := range types {
+				if _, _, _, err := limit.Parse(c.Account.Currency); err != nil {
+					problems = append(problems, fmt.Sprintf("limits.%s.%s.%v", tier, txType, err))
+				}
+			}
+		}
+		for i, rule := range c.Fees {
+			if err := rule.Validate(c.Account.Currency); err != nil {
+				problems = append(problems, fmt.Sprintf("fees[%d] : %v", i, err))
+			}
+		}
+		if _, err := c.Risk.Rules(c.Account.Currency); err != nil {
+			problems = append(problems, fmt.Sprintf("risk.%v", err))
+		}
+		for product, bands := range c.Interest.Products {
+			if len(bands) == 0 {
+				problems = append(problems, fmt.Sprintf("interest.products.%s must have at least one band", product))
+			}
+			var previous *money.Money
+			for i, band := range bands {
+				from, _, err := band.Parse(c.Account.Currency)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("interest.products.%s[%d].%v", product, i, err))
+					continue
+				}
+				if previous != nil && from.Units <= previous.Units {
+					problems = append(problems, fmt.Sprintf("interest.products.%s bands must be sorted by increasing from", product))
+				}
+				previous = &from
+			}
+		}
+	}
+	if _, err := ParseRate(c.Interest.OverdraftRate); err != nil {
+		problems = append(problems, fmt.Sprintf("interest.overdraft_rate : %v", err))
+	}
+	if c.Interest.DayCount != 360 && c.Interest.DayCount != 365 {
+		problems = append(problems, "interest.day_count must be 360 or 365")
+	}
+	if _, ok := c.Interest.Products["current"]; !ok {
+		problems = append(problems, "interest.products must define the current product, the product of new accounts")
+	}
+	if c.Webhooks.Timeout <= 0 {
+		problems = append(problems, "webhooks.timeout must be positive")
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		problems = append(problems, "webhooks.max_attempts must be at least 1")
+	}
+	if c.Webhooks.RetryBase <= 0 {
+		problems = append(problems, "webhooks.retry_base must be positive")
+	}
+	if c.Webhooks.RetryMax < c.Webhooks.RetryBase {
+		problems = append(problems, "webhooks.retry_max must not be shorter than webhooks.retry_base")
+	}
+	if c.Webhooks.DisableAfter < 1 {
+		problems = append(problems, "webhooks.disable_after must be at least 1")
+	}
+	if _, ok := c.Limits["standard"]; !ok {
+		problems = append(problems, "limits must define the standard tier, the tier of new accounts")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration :\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (c *Config) InitialBalance() (money.Money, error) {
+	return money.Parse(c.Account.InitialBalance, c.Account.Currency)
+}
+
+func (c *Config) MinimumBalance() (money.Money, error) {
+	return money.Parse(c.Account.MinimumBalance, c.Account.Currency)
+}
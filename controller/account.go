@@ -2,30 +2,61 @@ package controller
 
 import (
 	"account-management/model"
+	"account-management/money"
+	re "account-management/redis"
 	"account-management/utils.go"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
-	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type AccountService struct {
-	AccountModel     *model.AccountModel
-	TransactionModel *model.TransactionModel
-	RedisClient      *redis.Client
-	MessageChannels  []string
+	UserModel          *model.UserModel
+	AccountModel       *model.AccountModel
+	TransactionModel   *model.TransactionModel
+	LedgerModel        *model.LedgerModel
+	IdempotencyModel   *model.IdempotencyModel
+	SessionModel       *model.SessionModel
+	StandingOrderModel *model.StandingOrderModel
+	LimitModel         *model.LimitModel
+	FeeModel           *model.FeeModel
+	InterestModel      *model.InterestModel
+	HoldModel          *model.HoldModel
+	ApprovalModel      *model.ApprovalModel
+	BeneficiaryModel   *model.BeneficiaryModel
+	RiskModel          *model.RiskModel
+	AuditModel         *model.AuditModel
+	WebhookModel       *model.WebhookModel
+	RedisClient        *redis.Client
+	MessageChannels    []string
 }
 
-func NewAccountService(accountModel *model.AccountModel, transactionModel *model.TransactionModel, rdb *redis.Client, messageChannels []string) *AccountService {
+func NewAccountService(db *gorm.DB, rdb *redis.Client, messageChannels []string) *AccountService {
+	accountModel := model.NewAccountModel(db)
+
 	return &AccountService{
-		AccountModel:     accountModel,
-		TransactionModel: transactionModel,
-		RedisClient:      rdb,
-		MessageChannels:  messageChannels,
+		UserModel:          model.NewUserModel(db),
+		AccountModel:       accountModel,
+		TransactionModel:   model.NewTransactionModel(db),
+		LedgerModel:        model.NewLedgerModel(db, accountModel),
+		IdempotencyModel:   model.NewIdempotencyModel(db),
+		SessionModel:       model.NewSessionModel(db),
+		StandingOrderModel: model.NewStandingOrderModel(db),
+		LimitModel:         model.NewLimitModel(db),
+		FeeModel:           model.NewFeeModel(db),
+		InterestModel:      model.NewInterestModel(db),
+		HoldModel:          model.NewHoldModel(db),
+		ApprovalModel:      model.NewApprovalModel(db),
+		BeneficiaryModel:   model.NewBeneficiaryModel(db),
+		RiskModel:          model.NewRiskModel(db),
+		AuditModel:         model.NewAuditModel(db),
+		WebhookModel:       model.NewWebhookModel(db),
+		RedisClient:        rdb,
+		MessageChannels:    messageChannels,
 	}
 }
 
@@ -39,8 +70,8 @@ func (a *AccountService) Register(c *gin.Context) {
 		return
 	}
 
-	var account model.Account
-	err = json.Unmarshal(body, &account)
+	var user model.User
+	err = json.Unmarshal(body, &user)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -49,7 +80,7 @@ func (a *AccountService) Register(c *gin.Context) {
 		return
 	}
 
-	if account.Username == "" || account.Password == "" {
+	if user.Username == "" || user.Password == "" {
 		c.JSON(500, gin.H{
 			"messages": errors.New("username or password must not be blank").Error(),
 			"status":   500,
@@ -57,7 +88,7 @@ func (a *AccountService) Register(c *gin.Context) {
 		return
 	}
 
-	account.Password, err = utils.HashPassword(account.Password)
+	user.Password, err = utils.HashPassword(user.Password)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -66,7 +97,21 @@ func (a *AccountService) Register(c *gin.Context) {
 		return
 	}
 
-	err = a.AccountModel.Register(&account)
+	var account *model.Account
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		account, err = s.UserModel.Register(&user)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{{
+			Event:      model.AuditRegistered,
+			ActorId:    user.UserId,
+			ActorRole:  user.Role,
+			TargetType: "user",
+			TargetId:   user.UserId,
+			After:      model.AuditValue(map[string]string{"username": user.Username, "account_id": account.AccountId}),
+		}}, nil
+	})
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -76,8 +121,11 @@ func (a *AccountService) Register(c *gin.Context) {
 	}
 
 	c.JSON(200, gin.H{
-		"messages": "Created successfully !",
-		"status":   200,
+		"messages":       "Created successfully !",
+		"user_id":        user.UserId,
+		"account_id":     account.AccountId,
+		"account_number": model.FormatAccountNumber(account.AccountNumber),
+		"status":         200,
 	})
 
 }
@@ -92,8 +140,8 @@ func (a *AccountService) Login(c *gin.Context) {
 		return
 	}
 
-	var account model.Account
-	err = json.Unmarshal(body, &account)
+	var user model.User
+	err = json.Unmarshal(body, &user)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -102,8 +150,8 @@ func (a *AccountService) Login(c *gin.Context) {
 		return
 	}
 
-	username := account.Username
-	password := account.Password
+	username := user.Username
+	password := user.Password
 
 	if username == "" || password == "" {
 		c.JSON(500, gin.H{
@@ -113,7 +161,8 @@ func (a *AccountService) Login(c *gin.Context) {
 		return
 	}
 
-	if !a.AccountModel.UserNameExist(account.Username) {
+	if !a.UserModel.UserNameExist(username) {
+		a.auditLoginFailure(c, username, "unknown username")
 		c.JSON(500, gin.H{
 			"messages": errors.New("username doesn't exist").Error(),
 			"status":   500,
@@ -121,9 +170,10 @@ func (a *AccountService) Login(c *gin.Context) {
 		return
 	}
 
-	hashedPassword := a.AccountModel.GetHashedPasswordByUsername(account.Username)
+	hashedPassword := a.UserModel.GetHashedPasswordByUsername(username)
 
 	if !utils.CheckPasswordHash(password, hashedPassword) {
+		a.auditLoginFailure(c, username, "wrong password")
 		c.JSON(500, gin.H{
 			"messages": errors.New("password is wrong").Error(),
 			"status":   500,
@@ -131,58 +181,92 @@ func (a *AccountService) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := utils.GenerateToken(username)
-	if err != nil {
+	userId, err := a.UserModel.GetUserIdByUserName(username)
+	if userId == "" || err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("fail to generate jwt-token").Error(),
+			"messages": errors.New("failed to get userId of the given username").Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	err = a.AccountModel.SaveToken(token, username)
-	if err != nil {
+	open, err := a.AccountModel.HasOpenAccount(userId)
+	if err != nil || !open {
+		a.auditLoginFailure(c, username, "account closed")
 		c.JSON(500, gin.H{
-			"messages": err.Error(),
+			"messages": errors.New("account is closed").Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"messages": "Logged in !",
-		"status":   200,
-		"token":    token,
-	})
+	var refreshToken, token string
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		session, newToken, err := s.SessionModel.Create(userId, "", utils.RefreshTokenLifespan())
+		if err != nil {
+			return nil, err
+		}
+		refreshToken = newToken
 
-}
+		role, err := s.UserModel.GetUserRole(userId)
+		if err != nil {
+			return nil, err
+		}
 
-func (a *AccountService) GetAllAccounts(c *gin.Context) {
+		token, err = utils.GenerateToken(userId, session.FamilyId, role)
+		if err != nil {
+			return nil, errors.New("fail to generate jwt-token")
+		}
 
-	accounts, err := a.AccountModel.GetList()
+		return []*model.AuditEntry{
+			{
+				Event:      model.AuditLoginSucceeded,
+				ActorId:    userId,
+				ActorRole:  role,
+				TargetType: "user",
+				TargetId:   userId,
+			},
+			tokenIssuedEntry(userId, role, session.FamilyId, "login"),
+		}, nil
+	})
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": "Failed to get all accounts from DB",
-			"status":   500,
-		})
-		return
-	}
-
-	if len(accounts) == 0 {
-		c.JSON(500, gin.H{
-			"messages": "There no accounts found in DB",
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
 	c.JSON(200, gin.H{
-		"messages": accounts,
-		"status":   200,
+		"messages":      "Logged in !",
+		"status":        200,
+		"token":         token,
+		"expires_in":    int64(utils.TokenLifespan().Seconds()),
+		"refresh_token": refreshToken,
 	})
 
 }
 
+// transactionRequest is what a client sets on a deposit, withdrawal or transfer ; every other
+// field of the transaction is set by the server.
+type transactionRequest struct {
+	Amount      money.Money `json:"amount"`
+	Receiver    string      `json:"receiver"`
+	Description string      `json:"description"`
+}
+
+func (r transactionRequest) transaction(txType string) model.Transaction {
+	tx := model.Transaction{
+		Type:        txType,
+		Amount:      r.Amount,
+		Description: r.Description,
+	}
+	if txType == "Transfer" {
+		tx.Receiver = r.Receiver
+	}
+	return tx
+}
+
 func (a *AccountService) Deposit(c *gin.Context) {
 
 	body, err := ioutil.ReadAll(c.Request.Body)
@@ -194,8 +278,8 @@ func (a *AccountService) Deposit(c *gin.Context) {
 		return
 	}
 
-	var transaction model.Transaction
-	err = json.Unmarshal(body, &transaction)
+	var request transactionRequest
+	err = json.Unmarshal(body, &request)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -203,8 +287,8 @@ func (a *AccountService) Deposit(c *gin.Context) {
 		})
 		return
 	}
+	transaction := request.transaction("Deposit")
 
-	transaction.Type = "Deposit"
 	err = a.checkValidTransaction(&transaction)
 	if err != nil {
 		c.JSON(500, gin.H{
@@ -214,42 +298,66 @@ func (a *AccountService) Deposit(c *gin.Context) {
 		return
 	}
 
-	bearerToken := c.Request.Header.Get("Authorization")
-
-	var token string
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		token = strings.Split(bearerToken, " ")[1]
+	var selection accountSelection
+	err = json.Unmarshal(body, &selection)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
 	}
 
-	accountId, err := a.AccountModel.GetAccountIdByToken(token)
-	if accountId == "" || err != nil {
+	accountId, err := a.operatedAccount(c, selection.AccountId)
+	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to get accountId with given token").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
 	transaction.Sender = accountId
-	transaction.TransactionId = uuid.NewString()
 
-	payload, err := json.Marshal(&transaction)
+	err = a.checkAccountStates(&transaction)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to marshal request").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	err = a.RedisClient.Publish(a.MessageChannels[0], payload).Err()
+	record, replayed, err := a.beginIdempotentRequest(c, &transaction, body)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to send request to task queue").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
+	if replayed {
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		err := s.TransactionModel.SavePending(&transaction)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{transactionEntry(&transaction)}, nil
+	})
+	if err == nil {
+		err = a.queueTransaction(&transaction)
+	}
+	if err != nil {
+		a.respondTransaction(c, record, 500, gin.H{
+			"messages":       err.Error(),
+			"transaction_id": transaction.TransactionId,
+			"status":         500,
+		})
+		return
+	}
 
 	// err = a.ProcessTransaction(&transaction)
 	// if err != nil {
@@ -260,7 +368,7 @@ func (a *AccountService) Deposit(c *gin.Context) {
 	// 	return
 	// }
 
-	c.JSON(200, gin.H{
+	a.respondTransaction(c, record, 200, gin.H{
 		"messages":       "your deposit request is processing !",
 		"transaction_id": transaction.TransactionId,
 		"status":         200,
@@ -279,8 +387,8 @@ func (a *AccountService) Withdraw(c *gin.Context) {
 		return
 	}
 
-	var transaction model.Transaction
-	err = json.Unmarshal(body, &transaction)
+	var request transactionRequest
+	err = json.Unmarshal(body, &request)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -288,8 +396,8 @@ func (a *AccountService) Withdraw(c *gin.Context) {
 		})
 		return
 	}
+	transaction := request.transaction("Withdraw")
 
-	transaction.Type = "Withdraw"
 	err = a.checkValidTransaction(&transaction)
 	if err != nil {
 		c.JSON(500, gin.H{
@@ -299,42 +407,66 @@ func (a *AccountService) Withdraw(c *gin.Context) {
 		return
 	}
 
-	bearerToken := c.Request.Header.Get("Authorization")
-
-	var token string
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		token = strings.Split(bearerToken, " ")[1]
+	var selection accountSelection
+	err = json.Unmarshal(body, &selection)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
 	}
 
-	accountId, err := a.AccountModel.GetAccountIdByToken(token)
-	if accountId == "" || err != nil {
+	accountId, err := a.operatedAccount(c, selection.AccountId)
+	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to get accountId with given token").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
 	transaction.Sender = accountId
-	transaction.TransactionId = uuid.NewString()
 
-	payload, err := json.Marshal(&transaction)
+	err = a.checkAccountStates(&transaction)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to marshal request").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	err = a.RedisClient.Publish(a.MessageChannels[0], payload).Err()
+	record, replayed, err := a.beginIdempotentRequest(c, &transaction, body)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to send request to task queue").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
+	if replayed {
+		return
+	}
+
+	required, err := a.enqueueOrAwaitApproval(c, &transaction)
+	if err != nil {
+		a.respondTransaction(c, record, 500, gin.H{
+			"messages":       err.Error(),
+			"transaction_id": transaction.TransactionId,
+			"status":         500,
+		})
+		return
+	}
+	if required > 1 {
+		a.respondTransaction(c, record, 200, gin.H{
+			"messages":           "your withdraw request is awaiting approval !",
+			"transaction_id":     transaction.TransactionId,
+			"approvals_required": required,
+			"status":             200,
+		})
+		return
+	}
 
 	// err = a.ProcessTransaction(&transaction)
 	// if err != nil {
@@ -345,7 +477,7 @@ func (a *AccountService) Withdraw(c *gin.Context) {
 	// 	return
 	// }
 
-	c.JSON(200, gin.H{
+	a.respondTransaction(c, record, 200, gin.H{
 		"messages":       "your withdraw request is processing !",
 		"transaction_id": transaction.TransactionId,
 		"status":         200,
@@ -364,8 +496,8 @@ func (a *AccountService) Transfer(c *gin.Context) {
 		return
 	}
 
-	var transaction model.Transaction
-	err = json.Unmarshal(body, &transaction)
+	var request transactionRequest
+	err = json.Unmarshal(body, &request)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -373,9 +505,20 @@ func (a *AccountService) Transfer(c *gin.Context) {
 		})
 		return
 	}
+	transaction := request.transaction("Transfer")
 
-	transaction.Type = "Transfer"
-	err = a.checkValidTransaction(&transaction)
+	var selection accountSelection
+	err = json.Unmarshal(body, &selection)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var payee payeeSelection
+	err = json.Unmarshal(body, &payee)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": err.Error(),
@@ -384,31 +527,73 @@ func (a *AccountService) Transfer(c *gin.Context) {
 		return
 	}
 
-	bearerToken := c.Request.Header.Get("Authorization")
+	// a transfer between the caller's own accounts names the receiving account instead of a username
+	if selection.ReceiverAccountId != "" {
+		transaction.Receiver = selection.ReceiverAccountId
+	}
 
-	var token string
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		token = strings.Split(bearerToken, " ")[1]
+	// so does a transfer to an account number or a beneficiary, once the payee is confirmed
+	if payee.ReceiverAccountNumber != "" || payee.BeneficiaryId != "" {
+		check, err := a.transferPayee(c, payee)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": err.Error(),
+				"status":   500,
+			})
+			return
+		}
+		if check.Result != model.PayeeMatch && !payee.PayeeConfirmed {
+			c.JSON(500, gin.H{
+				"messages": errors.New("payee name doesn't match the account, check it or pass payee_confirmed to send anyway").Error(),
+				"payee":    payeeResponse(check),
+				"status":   500,
+			})
+			return
+		}
+		transaction.Receiver = check.AccountId
 	}
 
-	sender, err := a.AccountModel.GetAccountIdByToken(token)
-	if sender == "" || err != nil {
+	err = a.checkValidTransaction(&transaction)
+	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to get your accountId with given token").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	receiver, err := a.AccountModel.GetAccountIdByUserName(transaction.Receiver)
-	if receiver == "" || err != nil {
+	sender, err := a.operatedAccount(c, selection.AccountId)
+	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("receiver doesn't exist, make sure you pass a right username").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
+	var receiver string
+	if payee.ReceiverAccountNumber != "" || payee.BeneficiaryId != "" {
+		receiver = transaction.Receiver
+	} else if selection.ReceiverAccountId != "" {
+		receiver, err = a.callerAccount(c, selection.ReceiverAccountId)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": errors.New("receiver account doesn't exist among your accounts").Error(),
+				"status":   500,
+			})
+			return
+		}
+	} else {
+		receiver, err = a.AccountModel.GetAccountIdByUserName(transaction.Receiver)
+		if receiver == "" || err != nil {
+			c.JSON(500, gin.H{
+				"messages": errors.New("receiver doesn't exist, make sure you pass a right username").Error(),
+				"status":   500,
+			})
+			return
+		}
+	}
+
 	if sender == receiver {
 		c.JSON(500, gin.H{
 			"messages": errors.New("receiver can't be sender").Error(),
@@ -419,27 +604,48 @@ func (a *AccountService) Transfer(c *gin.Context) {
 
 	transaction.Sender = sender
 	transaction.Receiver = receiver
-	transaction.TransactionId = uuid.NewString()
 
-	payload, err := json.Marshal(&transaction)
+	err = a.checkAccountStates(&transaction)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to marshal request").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	err = a.RedisClient.Publish(a.MessageChannels[0], payload).Err()
+	record, replayed, err := a.beginIdempotentRequest(c, &transaction, body)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to send request to task queue").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
+	if replayed {
+		return
+	}
 
-	c.JSON(200, gin.H{
+	required, err := a.enqueueOrAwaitApproval(c, &transaction)
+	if err != nil {
+		a.respondTransaction(c, record, 500, gin.H{
+			"messages":       err.Error(),
+			"transaction_id": transaction.TransactionId,
+			"status":         500,
+		})
+		return
+	}
+	if required > 1 {
+		a.respondTransaction(c, record, 200, gin.H{
+			"messages":           "your transfer request is awaiting approval !",
+			"transaction_id":     transaction.TransactionId,
+			"approvals_required": required,
+			"status":             200,
+		})
+		return
+	}
+
+	a.respondTransaction(c, record, 200, gin.H{
 		"messages":       "your transfer request is processing !",
 		"transaction_id": transaction.TransactionId,
 		"status":         200,
@@ -447,18 +653,89 @@ func (a *AccountService) Transfer(c *gin.Context) {
 
 }
 
+// SubmitTransaction runs the checks of the transaction endpoints on tx, whose id is already
+// assigned, then queues it, or makes it await approval like the endpoints do. It is how
+// transactions not coming from a request are submitted ; initiatorId is the user on whose behalf
+// tx is made, blank when there is none, and counts as the first approval.
+func (a *AccountService) SubmitTransaction(tx *model.Transaction, initiatorId string) (int, error) {
+	err := a.checkValidTransaction(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	err = a.checkAccountStates(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	return a.awaitApprovalOrEnqueue(tx, initiatorId)
+}
+
+// queueTransaction hands tx, already saved as PENDING and committed, to the task queue. If the
+// queue can't be reached the transaction is marked FAILED so its status never stays pending
+// forever.
+func (a *AccountService) queueTransaction(tx *model.Transaction) error {
+	payload, err := json.Marshal(tx)
+	if err != nil {
+		a.TransactionModel.Fail(tx, model.ReasonProcessingError, err.Error())
+		return errors.New("failed to marshal request")
+	}
+
+	_, err = re.Enqueue(a.RedisClient, a.MessageChannels[0], payload)
+	if err != nil {
+		a.TransactionModel.Fail(tx, model.ReasonQueueUnavailable, err.Error())
+		return errors.New("failed to send request to task queue")
+	}
+
+	return nil
+}
+
+// checkAccountStates refuses early the transactions the worker would reject because an account
+// is frozen, closed, ... The worker checks again before posting.
+func (a *AccountService) checkAccountStates(tx *model.Transaction) error {
+	postings, err := model.TransactionPostings(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		if model.IsInternalAccount(p.AccountId) {
+			continue
+		}
+		err := a.AccountModel.CheckAccountMovement(p.AccountId, p.Amount.IsNegative())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (a *AccountService) checkValidTransaction(tx *model.Transaction) error {
 
+	// captures and reversals are only created by the server, from a hold or a transaction
+	if tx.Type == "Deposit" || tx.Type == "Withdraw" || tx.Type == "Transfer" {
+		if tx.ReversalOf != "" {
+			return errors.New("only reversals can reverse a transaction")
+		}
+		if tx.HoldId != "" {
+			return errors.New("holds are captured through /api/holds")
+		}
+	}
+
 	if tx.Type == "Transfer" {
 		if tx.Receiver == "" {
 			return errors.New("receiver must not be blank")
 		}
 	}
 
-	if tx.Amount <= 0 {
+	if !tx.Amount.IsPositive() {
 		return errors.New("amount must be greater than 0")
 	}
 
+	if _, err := money.Exponent(tx.Amount.Currency); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -480,40 +757,70 @@ func (a *AccountService) CheckTransactionStatus(c *gin.Context) {
 		return
 	}
 
-	exist := a.TransactionModel.GetTransactionState(txid)
-	if !exist {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	transaction, err := a.TransactionModel.GetTransaction(txid)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	// customers only see the transactions of their accounts ; staff roles may look up any of them
+	isParty := false
+	for _, accountId := range []string{transaction.Sender, transaction.Receiver} {
+		if _, err := a.AccountModel.HolderRole(accountId, principal.UserId); err == nil {
+			isParty = true
+		}
+	}
+	if !isParty && !model.HasPermission(principal.Role, model.PermissionReadTransactions) {
 		c.JSON(500, gin.H{
-			"messages": errors.New("transaction doesn't exist or still be processing").Error(),
+			"messages": model.ErrTransactionNotFound.Error(),
 			"status":   500,
 		})
 		return
 	}
 
 	c.JSON(200, gin.H{
-		"state":  "Finished",
-		"status": 200,
+		"transaction_id": transaction.TransactionId,
+		"type":           transaction.Type,
+		"state":          transaction.State,
+		"reason":         transaction.Reason,
+		"detail":         transaction.Detail,
+		"risk_rule":      transaction.RiskRule,
+		"amount":         transaction.Amount,
+		"fee":            transaction.Fee,
+		"sender":         transaction.Sender,
+		"receiver":       transaction.Receiver,
+		"created_time":   transaction.CreatedTime,
+		"completed_time": transaction.CompletedTime,
+		"rejected_time":  transaction.RejectedTime,
+		"failed_time":    transaction.FailedTime,
+		"status":         200,
 	})
 
 }
 
 func (a *AccountService) CheckAccountBalance(c *gin.Context) {
-	bearerToken := c.Request.Header.Get("Authorization")
-
-	var token string
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		token = strings.Split(bearerToken, " ")[1]
-	}
-
-	accountId, err := a.AccountModel.GetAccountIdByToken(token)
-	if accountId == "" || err != nil {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
 		c.JSON(500, gin.H{
-			"messages": errors.New("failed to get accountId with given token").Error(),
+			"messages": err.Error(),
 			"status":   500,
 		})
 		return
 	}
 
-	balance, err := a.AccountModel.GetAccountBalance(accountId)
+	funds, err := a.AccountModel.GetFunds(accountId)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"messages": errors.New("failed to get balance of your given account").Error(),
@@ -523,9 +830,10 @@ func (a *AccountService) CheckAccountBalance(c *gin.Context) {
 	}
 
 	c.JSON(200, gin.H{
-		"account_id": accountId,
-		"balance":    balance,
-		"status":     200,
+		"account_id":        accountId,
+		"balance":           funds.Balance,
+		"available_balance": funds.AvailableBalance,
+		"status":            200,
 	})
 
 }
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"account-management/model"
+	re "account-management/redis"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (a *AccountService) GetAllAccounts(c *gin.Context) {
+
+	accounts, err := a.AccountModel.GetList()
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": "Failed to get all accounts from DB",
+			"status":   500,
+		})
+		return
+	}
+
+	if len(accounts) == 0 {
+		c.JSON(500, gin.H{
+			"messages": "There no accounts found in DB",
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": accounts,
+		"status":   200,
+	})
+
+}
+
+func (a *AccountService) GetAccount(c *gin.Context) {
+	account, err := a.AccountModel.GetAccount(c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": account,
+		"status":   200,
+	})
+}
+
+// GetAccountTransactions returns the history of any account, with the filters of /api/transactions.
+func (a *AccountService) GetAccountTransactions(c *gin.Context) {
+	accountId := c.Param("account_id")
+
+	if _, err := a.AccountModel.GetAccount(accountId); err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	a.respondHistory(c, accountId)
+}
+
+type accountStateRequest struct {
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+}
+
+// ChangeAccountState moves an account to another state (e.g. FROZEN during an investigation).
+// The reason is mandatory and recorded with the admin who made the change.
+func (a *AccountService) ChangeAccountState(c *gin.Context) {
+	accountId := c.Param("account_id")
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request accountStateRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	state, err := model.ParseAccountState(request.State)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var change *model.AccountStateChange
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		change, err = s.AccountModel.ChangeAccountState(accountId, state, request.Reason, principal.UserId)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditAccountState, "account", accountId, change.FromState.String(), change.ToState.String(), request.Reason)}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Account state changed !",
+		"change":   change,
+		"status":   200,
+	})
+}
+
+func (a *AccountService) GetAccountStateHistory(c *gin.Context) {
+	accountId := c.Param("account_id")
+
+	if _, err := a.AccountModel.GetAccount(accountId); err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	changes, err := a.AccountModel.GetAccountStateHistory(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": accountId,
+		"changes":    changes,
+		"status":     200,
+	})
+}
+
+type userRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// ChangeUserRole gives a role to a user ; it applies to every account of the user.
+func (a *AccountService) ChangeUserRole(c *gin.Context) {
+	userId := c.Param("user_id")
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request userRoleRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if principal.UserId == userId {
+		c.JSON(500, gin.H{
+			"messages": errors.New("you can't change your own role").Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		previous, err := s.UserModel.GetUserRole(userId)
+		if err != nil {
+			return nil, err
+		}
+		err = s.UserModel.SetUserRole(userId, request.Role)
+		if err != nil {
+			return nil, err
+		}
+		// tokens carry the role, so the ones already issued must not outlive the change
+		err = re.RevokeUser(s.RedisClient, userId, utils.TokenLifespan())
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditUserRole, "user", userId, previous, request.Role, "")}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "User role changed !",
+		"user_id":  userId,
+		"role":     request.Role,
+		"status":   200,
+	})
+}
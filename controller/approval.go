@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type approvalRequest struct {
+	Decision string `json:"decision"`
+	Comment  string `json:"comment"`
+}
+
+// enqueueOrAwaitApproval queues tx, unless the approval policy of its account asks for more
+// holders than the caller : tx then awaits their approval and the number of approvals required
+// is returned. tx is saved with the audit entry of the request.
+func (a *AccountService) enqueueOrAwaitApproval(c *gin.Context, tx *model.Transaction) (int, error) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		return 0, err
+	}
+
+	var required int
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		required, err = s.saveOrAwaitApproval(tx, principal.UserId)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{transactionEntry(tx)}, nil
+	})
+	if err != nil || required > 0 {
+		return required, err
+	}
+	return 0, a.queueTransaction(tx)
+}
+
+// awaitApprovalOrEnqueue is enqueueOrAwaitApproval for a transaction initiated by initiatorId
+// outside of a request.
+func (a *AccountService) awaitApprovalOrEnqueue(tx *model.Transaction, initiatorId string) (int, error) {
+	required, err := a.saveOrAwaitApproval(tx, initiatorId)
+	if err != nil || required > 0 {
+		return required, err
+	}
+	return 0, a.queueTransaction(tx)
+}
+
+// saveOrAwaitApproval saves tx as PENDING, or as awaiting the approval of other holders when the
+// policy of its account asks for more than one ; the number of approvals required is then
+// returned. A PENDING tx is queued by the caller once saved.
+func (a *AccountService) saveOrAwaitApproval(tx *model.Transaction, initiatorId string) (int, error) {
+	required, err := a.ApprovalModel.RequiredApprovals(tx)
+	if err != nil {
+		return 0, err
+	}
+	if required <= 1 {
+		return 0, a.TransactionModel.SavePending(tx)
+	}
+	return required, a.ApprovalModel.SubmitForApproval(tx, initiatorId, required, a.TransactionModel)
+}
+
+// checkNoApprovalRequired refuses the transactions that would have to wait for the approval of
+// other holders but can't, such as hold captures : the funds of a hold are reserved up front.
+func (a *AccountService) checkNoApprovalRequired(tx *model.Transaction) error {
+	required, err := a.ApprovalModel.RequiredApprovals(tx)
+	if err != nil {
+		return err
+	}
+	if required > 1 {
+		return fmt.Errorf("the account requires %d approvals for this amount, make a %s instead of using a hold", required, strings.ToLower(tx.Type))
+	}
+	return nil
+}
+
+// ListPendingApprovals returns the transactions awaiting approval on the accounts the caller
+// operates.
+func (a *AccountService) ListPendingApprovals(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	pending, err := a.ApprovalModel.Pending(principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"approvals": pending,
+		"status":    200,
+	})
+}
+
+// DecideApproval approves or rejects a transaction awaiting approval ; the last approval needed
+// queues it.
+func (a *AccountService) DecideApproval(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request approvalRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	tx, ready, err := a.ApprovalModel.Decide(c.Param("transaction_id"), principal.UserId, request.Decision, request.Comment, a.AccountModel)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if ready {
+		err = a.queueTransaction(tx)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages":       err.Error(),
+				"transaction_id": tx.TransactionId,
+				"status":         500,
+			})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"messages":       "Decision recorded !",
+		"transaction_id": tx.TransactionId,
+		"state":          tx.State,
+		"status":         200,
+	})
+}
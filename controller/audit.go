@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// describeRequest sets the caller, its address and the request id of entry.
+func describeRequest(c *gin.Context, entry *model.AuditEntry) {
+	if principal, err := utils.CurrentPrincipal(c); err == nil && entry.ActorId == "" {
+		entry.ActorId = principal.UserId
+		entry.ActorRole = principal.Role
+	}
+	entry.IP = c.ClientIP()
+	entry.UserAgent = c.Request.UserAgent()
+	entry.RequestId = utils.RequestId(c)
+}
+
+// audit appends entry, about a request which changed nothing, to the audit log. An entry which
+// can't be written is printed.
+func (a *AccountService) audit(c *gin.Context, entry *model.AuditEntry) {
+	describeRequest(c, entry)
+
+	if err := a.AuditModel.Append(entry); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// auditedTransaction runs action with a copy of the service whose models work in one database
+// transaction, and appends the entries action returns to the audit log in that transaction : an
+// action is never committed without its entries. action must not queue anything, the queue would
+// see its changes before they are committed.
+func (a *AccountService) auditedTransaction(c *gin.Context, action func(s *AccountService) ([]*model.AuditEntry, error)) error {
+	return a.AuditModel.DB.Transaction(func(tx *gorm.DB) error {
+		entries, err := action(NewAccountService(tx, a.RedisClient, a.MessageChannels))
+		if err != nil {
+			return err
+		}
+
+		auditModel := model.NewAuditModel(tx)
+		for _, entry := range entries {
+			describeRequest(c, entry)
+			if err := auditModel.Append(entry, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// changeEntry is the entry of an admin action changing target from before to after.
+func changeEntry(event, targetType, targetId string, before, after interface{}, detail string) *model.AuditEntry {
+	return &model.AuditEntry{
+		Event:      event,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Before:     model.AuditValue(before),
+		After:      model.AuditValue(after),
+		Detail:     detail,
+	}
+}
+
+// auditLoginFailure records a failed login attempt on username.
+func (a *AccountService) auditLoginFailure(c *gin.Context, username, reason string) {
+	a.audit(c, &model.AuditEntry{
+		Event:      model.AuditLoginFailed,
+		TargetType: "username",
+		TargetId:   username,
+		Detail:     reason,
+	})
+}
+
+// tokenIssuedEntry is the entry of the access and refresh tokens given to userId for the session
+// family sessionId, on a login or a refresh.
+func tokenIssuedEntry(userId, role, sessionId, grant string) *model.AuditEntry {
+	return &model.AuditEntry{
+		Event:      model.AuditTokenIssued,
+		ActorId:    userId,
+		ActorRole:  role,
+		TargetType: "session",
+		TargetId:   sessionId,
+		Detail:     grant,
+	}
+}
+
+// transactionEntry is the entry of the request which created tx.
+func transactionEntry(tx *model.Transaction) *model.AuditEntry {
+	return &model.AuditEntry{
+		Event:      model.AuditTransactionRequest,
+		TargetType: "transaction",
+		TargetId:   tx.TransactionId,
+		After: model.AuditValue(map[string]interface{}{
+			"type":     tx.Type,
+			"sender":   tx.Sender,
+			"receiver": tx.Receiver,
+			"amount":   tx.Amount,
+			"state":    tx.State,
+		}),
+	}
+}
+
+// GetAuditLog returns entries of the audit log, newest first, filtered by ?event=, ?actor_id=,
+// ?target_id= and ?request_id= ; pass the seq of the last entry as ?before to get the next page.
+func (a *AccountService) GetAuditLog(c *gin.Context) {
+	filter := model.AuditFilter{
+		Event:     c.Query("event"),
+		ActorId:   c.Query("actor_id"),
+		TargetId:  c.Query("target_id"),
+		RequestId: c.Query("request_id"),
+		Limit:     100,
+	}
+
+	if before := c.Query("before"); before != "" {
+		seq, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || seq <= 0 {
+			c.JSON(500, gin.H{
+				"messages": "before must be the seq of an entry",
+				"status":   500,
+			})
+			return
+		}
+		filter.BeforeSeq = seq
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > 1000 {
+			c.JSON(500, gin.H{
+				"messages": "limit must be between 1 and 1000",
+				"status":   500,
+			})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := a.AuditModel.List(filter)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"entries": entries,
+		"status":  200,
+	})
+}
@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+type beneficiaryRequest struct {
+	AccountNumber string `json:"account_number"`
+	Name          string `json:"name"`
+	Nickname      string `json:"nickname"`
+}
+
+// payeeSelection names the receiver of a transfer by account number, with the name of the payee
+// the payer expects, or by saved beneficiary. PayeeConfirmed sends the transfer even though the
+// name doesn't match the account.
+type payeeSelection struct {
+	ReceiverAccountNumber string `json:"receiver_account_number"`
+	PayeeName             string `json:"payee_name"`
+	BeneficiaryId         string `json:"beneficiary_id"`
+	PayeeConfirmed        bool   `json:"payee_confirmed"`
+}
+
+// transferPayee confirms the payee of a transfer named by account number or beneficiary.
+func (a *AccountService) transferPayee(c *gin.Context, payee payeeSelection) (*model.PayeeCheck, error) {
+	number, name := payee.ReceiverAccountNumber, payee.PayeeName
+	if payee.BeneficiaryId != "" {
+		principal, err := utils.CurrentPrincipal(c)
+		if err != nil {
+			return nil, err
+		}
+		beneficiary, err := a.BeneficiaryModel.Get(payee.BeneficiaryId, principal.UserId)
+		if err != nil {
+			return nil, err
+		}
+		number, name = beneficiary.AccountNumber, beneficiary.Name
+	}
+
+	if name == "" {
+		return nil, errors.New("payee_name must not be blank, it is checked against the name of the account")
+	}
+	return a.AccountModel.ConfirmPayee(number, name)
+}
+
+// payeeResponse is what payers are told of a confirmation of payee.
+func payeeResponse(check *model.PayeeCheck) gin.H {
+	response := gin.H{
+		"account_number": model.FormatAccountNumber(check.AccountNumber),
+		"result":         check.Result,
+	}
+	if check.Name != "" {
+		response["name"] = check.Name
+	}
+	return response
+}
+
+// ConfirmPayee tells whether the name the caller expects matches the account it's about to pay.
+func (a *AccountService) ConfirmPayee(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request beneficiaryRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	check, err := a.AccountModel.ConfirmPayee(request.AccountNumber, request.Name)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"payee":  payeeResponse(check),
+		"status": 200,
+	})
+}
+
+// CreateBeneficiary saves a payee of the caller, after confirming its name.
+func (a *AccountService) CreateBeneficiary(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request beneficiaryRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	beneficiary := model.Beneficiary{
+		UserId:        principal.UserId,
+		AccountNumber: request.AccountNumber,
+		Name:          request.Name,
+		Nickname:      request.Nickname,
+	}
+	err = a.BeneficiaryModel.Save(&beneficiary, a.AccountModel)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":    "Created successfully !",
+		"beneficiary": beneficiary,
+		"status":      200,
+	})
+}
+
+func (a *AccountService) ListBeneficiaries(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	beneficiaries, err := a.BeneficiaryModel.ListByUser(principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"beneficiaries": beneficiaries,
+		"status":        200,
+	})
+}
+
+// RenameBeneficiary changes the nickname of a beneficiary of the caller.
+func (a *AccountService) RenameBeneficiary(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request beneficiaryRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.BeneficiaryModel.Rename(c.Param("beneficiary_id"), principal.UserId, request.Nickname)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Beneficiary renamed !",
+		"status":   200,
+	})
+}
+
+// VerifyBeneficiary confirms the payee of a beneficiary of the caller again, with a corrected
+// name when the body has one.
+func (a *AccountService) VerifyBeneficiary(c *gin.Context) {
+	var request beneficiaryRequest
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err == nil && len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	beneficiary, err := a.BeneficiaryModel.Verify(c.Param("beneficiary_id"), principal.UserId, request.Name, a.AccountModel)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"beneficiary": beneficiary,
+		"status":      200,
+	})
+}
+
+func (a *AccountService) DeleteBeneficiary(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.BeneficiaryModel.Delete(c.Param("beneficiary_id"), principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Beneficiary deleted !",
+		"status":   200,
+	})
+}
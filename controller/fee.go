@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"account-management/money"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteFee returns the fee the caller would be charged for a transaction of the given type and
+// amount if it was submitted now ; the worker computes it again when processing the transaction.
+func (a *AccountService) QuoteFee(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	txTypes, err := parseListParam(c.Query("type"), transactionTypes, "type")
+	if err == nil && len(txTypes) != 1 {
+		err = errors.New("you must pass one type in parameter")
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	amount, err := money.Parse(c.Query("amount"), c.Query("currency"))
+	if err == nil && !amount.IsPositive() {
+		err = errors.New("amount must be greater than 0")
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	quote, err := a.FeeModel.Quote(accountId, txTypes[0], amount)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"type":           quote.Type,
+		"amount":         quote.Amount,
+		"fee":            quote.Fee,
+		"total":          quote.Total,
+		"free_remaining": quote.FreeRemaining,
+		"status":         200,
+	})
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// floorRequest holds the new floor of an account as a decimal amount in its currency, negative
+// for an overdraft ; blank puts the account back on the default minimum balance.
+type floorRequest struct {
+	Floor string `json:"floor"`
+}
+
+// CheckAvailableFunds returns the caller's balance, floor and what it can spend, overdraft included.
+func (a *AccountService) CheckAvailableFunds(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	funds, err := a.AccountModel.GetFunds(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": accountId,
+		"funds":      funds,
+		"status":     200,
+	})
+}
+
+// ChangeAccountFloor sets the floor of an account ; a negative floor grants an overdraft.
+func (a *AccountService) ChangeAccountFloor(c *gin.Context) {
+	accountId := c.Param("account_id")
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request floorRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var floorUnits *int64
+	if request.Floor != "" {
+		balance, err := a.AccountModel.GetAccountBalance(accountId)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": err.Error(),
+				"status":   500,
+			})
+			return
+		}
+
+		floor, err := money.Parse(request.Floor, balance.Currency)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": err.Error(),
+				"status":   500,
+			})
+			return
+		}
+		floorUnits = &floor.Units
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		previous, err := s.AccountModel.GetAccount(accountId)
+		if err != nil {
+			return nil, err
+		}
+		err = s.AccountModel.SetAccountFloor(accountId, floorUnits)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditAccountFloor, "account", accountId, previous.FloorUnits, floorUnits, "")}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	funds, err := a.AccountModel.GetFunds(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Account floor changed !",
+		"funds":    funds,
+		"status":   200,
+	})
+}
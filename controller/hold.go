@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultHoldExpiry is how long a hold reserves funds when the request sets no expiry.
+const defaultHoldExpiry = 7 * 24 * time.Hour
+
+type holdRequest struct {
+	AccountId   string      `json:"account_id"`
+	Amount      money.Money `json:"amount"`
+	Receiver    string      `json:"receiver"`
+	Description string      `json:"description"`
+	// ExpiresIn is a duration such as "72h", ExpiresAt a RFC3339 time ; at most one is set.
+	ExpiresIn string `json:"expires_in"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// captureRequest holds the captured amount ; a zero amount captures the whole hold.
+type captureRequest struct {
+	Amount money.Money `json:"amount"`
+}
+
+// callerHold returns the hold holdId if it is on an account the caller operates.
+func (a *AccountService) callerHold(c *gin.Context, holdId string) (*model.Hold, error) {
+	hold, err := a.HoldModel.Get(holdId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.operatedAccount(c, hold.AccountId); err != nil {
+		return nil, model.ErrHoldNotFound
+	}
+	return hold, nil
+}
+
+// PlaceHold reserves funds of the caller, to be captured later as a withdrawal, or as a
+// transfer when the hold names a receiver (a username).
+func (a *AccountService) PlaceHold(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request holdRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accountId, err := a.operatedAccount(c, request.AccountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.checkValidTransaction(&model.Transaction{Amount: request.Amount})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	hold := model.Hold{
+		AccountId:   accountId,
+		Amount:      request.Amount,
+		Description: request.Description,
+		ExpiresTime: time.Now().Add(defaultHoldExpiry),
+	}
+
+	if request.Receiver != "" {
+		receiver, err := a.AccountModel.GetAccountIdByUserName(request.Receiver)
+		if receiver == "" || err != nil {
+			c.JSON(500, gin.H{
+				"messages": errors.New("receiver doesn't exist, make sure you pass a right username").Error(),
+				"status":   500,
+			})
+			return
+		}
+		if receiver == accountId {
+			c.JSON(500, gin.H{
+				"messages": errors.New("receiver can't be sender").Error(),
+				"status":   500,
+			})
+			return
+		}
+		hold.Receiver = receiver
+	}
+
+	// a capture can't wait for other holders, so a hold is refused over the approval threshold
+	capture := &model.Transaction{Type: "Withdraw", Sender: accountId, Amount: hold.Amount}
+	if hold.Receiver != "" {
+		capture.Type = "Transfer"
+	}
+	err = a.checkNoApprovalRequired(capture)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	switch {
+	case request.ExpiresIn != "" && request.ExpiresAt != "":
+		err = errors.New("expires_in and expires_at can't be both set")
+	case request.ExpiresIn != "":
+		var expiresIn time.Duration
+		expiresIn, err = time.ParseDuration(request.ExpiresIn)
+		hold.ExpiresTime = time.Now().Add(expiresIn)
+	case request.ExpiresAt != "":
+		hold.ExpiresTime, err = time.Parse(time.RFC3339, request.ExpiresAt)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.HoldModel.Place(&hold, a.AccountModel)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Placed successfully !",
+		"hold":     hold,
+		"status":   200,
+	})
+}
+
+func (a *AccountService) ListHolds(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	holds, err := a.HoldModel.ListByAccount(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	funds, err := a.AccountModel.GetFunds(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"holds":             holds,
+		"balance":           funds.Balance,
+		"held":              funds.Held,
+		"available_balance": funds.AvailableBalance,
+		"status":            200,
+	})
+}
+
+// CaptureHold turns one of the caller's holds, fully or partly, into a withdrawal or a transfer.
+// The rest of a partly captured hold is released.
+func (a *AccountService) CaptureHold(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request captureRequest
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": err.Error(),
+				"status":   500,
+			})
+			return
+		}
+	}
+
+	hold, err := a.callerHold(c, c.Param("hold_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	transaction := model.Transaction{
+		TransactionId: uuid.NewString(),
+		Sender:        hold.AccountId,
+		Receiver:      hold.Receiver,
+		Amount:        request.Amount,
+		Type:          "Withdraw",
+		RequestId:     utils.RequestId(c),
+	}
+	if transaction.Amount.IsZero() {
+		transaction.Amount = hold.Amount
+	}
+	if hold.Receiver != "" {
+		transaction.Type = "Transfer"
+	}
+
+	err = a.checkValidTransaction(&transaction)
+	if err == nil {
+		err = a.checkAccountStates(&transaction)
+	}
+	if err == nil {
+		// the policy may have changed since the hold was placed
+		err = a.checkNoApprovalRequired(&transaction)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		_, err := s.HoldModel.RequestCapture(hold.HoldId, hold.AccountId, &transaction, s.TransactionModel)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{transactionEntry(&transaction)}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.queueTransaction(&transaction)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages":       err.Error(),
+			"transaction_id": transaction.TransactionId,
+			"status":         500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":       "your capture request is processing !",
+		"transaction_id": transaction.TransactionId,
+		"status":         200,
+	})
+}
+
+func (a *AccountService) ReleaseHold(c *gin.Context) {
+	hold, err := a.callerHold(c, c.Param("hold_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.HoldModel.Release(hold.HoldId, hold.AccountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Released successfully !",
+		"status":   200,
+	})
+}
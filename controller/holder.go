@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"account-management/utils.go"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+type holderRequest struct {
+	Role string `json:"role"`
+}
+
+// approvalPolicyRequest holds the amount, in the currency of the account, over which Approvals
+// holders must approve a transaction.
+type approvalPolicyRequest struct {
+	Over      string `json:"over"`
+	Approvals int    `json:"approvals"`
+}
+
+// ListAccountHolders returns the holders of one of the caller's accounts.
+func (a *AccountService) ListAccountHolders(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	holders, err := a.AccountModel.ListHolders(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": accountId,
+		"holders":    holders,
+		"status":     200,
+	})
+}
+
+// SetAccountHolder makes the user username a holder of an account the caller owns, or changes
+// its role.
+func (a *AccountService) SetAccountHolder(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request holderRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accountId, err := a.ownedAccount(c, c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	userId, err := a.UserModel.GetUserIdByUserName(c.Param("username"))
+	if userId == "" || err != nil {
+		c.JSON(500, gin.H{
+			"messages": model.ErrUserNotFound.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.AccountModel.SetHolder(accountId, userId, request.Role)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":   "Account holder saved !",
+		"account_id": accountId,
+		"user_id":    userId,
+		"role":       request.Role,
+		"status":     200,
+	})
+}
+
+// RemoveAccountHolder takes an account the caller owns away from the user username ; holders
+// may also remove themselves.
+func (a *AccountService) RemoveAccountHolder(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	userId, err := a.UserModel.GetUserIdByUserName(c.Param("username"))
+	if userId == "" || err != nil {
+		c.JSON(500, gin.H{
+			"messages": model.ErrUserNotFound.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	check := a.ownedAccount
+	if userId == principal.UserId {
+		check = a.callerAccount
+	}
+	accountId, err := check(c, c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.AccountModel.RemoveHolder(accountId, userId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Account holder removed !",
+		"status":   200,
+	})
+}
+
+func (a *AccountService) ListApprovalPolicies(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	policies, err := a.ApprovalModel.Policies(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": accountId,
+		"policies":   policies,
+		"status":     200,
+	})
+}
+
+// SetApprovalPolicy sets how many holders must approve the transactions of a type over an amount
+// on an account the caller owns.
+func (a *AccountService) SetApprovalPolicy(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request approvalPolicyRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accountId, err := a.ownedAccount(c, c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	balance, err := a.AccountModel.GetAccountBalance(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if request.Over == "" {
+		request.Over = "0"
+	}
+	over, err := money.Parse(request.Over, balance.Currency)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	policy := model.ApprovalPolicy{
+		AccountId: accountId,
+		Type:      c.Param("type"),
+		Over:      over,
+		Approvals: request.Approvals,
+		UpdatedBy: principal.UserId,
+	}
+	err = a.ApprovalModel.SetPolicy(&policy, a.AccountModel)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Approval policy saved !",
+		"policy":   policy,
+		"status":   200,
+	})
+}
+
+func (a *AccountService) DeleteApprovalPolicy(c *gin.Context) {
+	accountId, err := a.ownedAccount(c, c.Param("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.ApprovalModel.DeletePolicy(accountId, c.Param("type"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Approval policy deleted !",
+		"status":   200,
+	})
+}
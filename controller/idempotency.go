@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// idempotencyInFlight is how long a request is assumed to be still running after it reserved its
+// key : a retry within it is refused, a later one resumes the request.
+const idempotencyInFlight = 30 * time.Second
+
+// transactionFingerprint identifies what a request asks for, independently of JSON formatting :
+// the type and sender of tx and every field of body, such as the payee inputs and description.
+func transactionFingerprint(tx *model.Transaction, body []byte) string {
+	var fields interface{}
+	canonical := body
+	if err := json.Unmarshal(body, &fields); err == nil {
+		// maps are encoded with sorted keys
+		canonical, _ = json.Marshal(fields)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s", tx.Type, tx.Sender, canonical)))
+	return hex.EncodeToString(sum[:])
+}
+
+// beginIdempotentRequest assigns the transaction id of tx. With an Idempotency-Key header the id
+// is the one reserved by the first request carrying that key ; when that request already got an
+// answer it is written again and replayed is true, so the handler must stop there. So it must
+// when the first request is still running, which is answered 409. body is the request, a key
+// reused with another body is refused.
+func (a *AccountService) beginIdempotentRequest(c *gin.Context, tx *model.Transaction, body []byte) (record *model.IdempotencyKey, replayed bool, err error) {
+	tx.RequestId = utils.RequestId(c)
+
+	key := strings.TrimSpace(c.GetHeader(idempotencyHeader))
+	if key == "" {
+		tx.TransactionId = uuid.NewString()
+		return nil, false, nil
+	}
+
+	if len(key) > 255 {
+		return nil, false, errors.New("Idempotency-Key must not be longer than 255 characters")
+	}
+
+	record, known, err := a.IdempotencyModel.Reserve(&model.IdempotencyKey{
+		AccountId:     tx.Sender,
+		Key:           key,
+		Fingerprint:   transactionFingerprint(tx, body),
+		TransactionId: uuid.NewString(),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx.TransactionId = record.TransactionId
+	tx.IdempotencyKey = key
+
+	if !known {
+		return record, false, nil
+	}
+
+	if record.ResponseCode != 0 {
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.ResponseCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+		return record, true, nil
+	}
+
+	if time.Since(record.CreatedTime) < idempotencyInFlight {
+		c.JSON(409, gin.H{
+			"messages": "a request with this Idempotency-Key is still in progress, retry later",
+			"status":   409,
+		})
+		return record, true, nil
+	}
+
+	// the first request died before answering : resume it with the same transaction id,
+	// unless its transaction already reached the queue
+	if _, err := a.TransactionModel.GetTransaction(record.TransactionId); err == nil {
+		c.Header("Idempotent-Replayed", "true")
+		a.respondTransaction(c, record, 200, gin.H{
+			"messages":       "your request is processing !",
+			"transaction_id": record.TransactionId,
+			"status":         200,
+		})
+		return record, true, nil
+	}
+
+	return record, false, nil
+}
+
+// respondTransaction writes the response of a transaction request and remembers it for replays.
+// A server error isn't remembered : the key is released so that the client can retry once the
+// failure, e.g. an unavailable queue, is over.
+func (a *AccountService) respondTransaction(c *gin.Context, record *model.IdempotencyKey, code int, body gin.H) {
+	if record != nil {
+		var err error
+		if code >= 500 {
+			err = a.IdempotencyModel.Release(record)
+		} else {
+			var payload []byte
+			payload, err = json.Marshal(body)
+			if err == nil {
+				err = a.IdempotencyModel.SaveResponse(record, code, string(payload))
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	c.JSON(code, body)
+}
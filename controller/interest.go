@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"account-management/model"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+type productRequest struct {
+	Product string `json:"product"`
+}
+
+// CheckAccruedInterest returns the interest the caller accrued and will be paid at the end of the month.
+func (a *AccountService) CheckAccruedInterest(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accrued, err := a.InterestModel.Accrued(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": accountId,
+		"accrued":    accrued.Amount(),
+		"status":     200,
+	})
+}
+
+// ChangeAccountProduct moves an account to another product, i.e. another interest rate table.
+func (a *AccountService) ChangeAccountProduct(c *gin.Context) {
+	accountId := c.Param("account_id")
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request productRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		previous, err := s.AccountModel.GetAccount(accountId)
+		if err != nil {
+			return nil, err
+		}
+		err = s.AccountModel.SetAccountProduct(accountId, request.Product)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditAccountProduct, "account", accountId, previous.Product, request.Product, "")}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Account product changed !",
+		"status":   200,
+	})
+}
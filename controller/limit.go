@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"account-management/config"
+	"account-management/model"
+	"account-management/utils.go"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitsRequest holds decimal amounts in the currency of the account ; blank means no limit.
+type limitsRequest struct {
+	PerTransaction string `json:"per_transaction"`
+	Daily          string `json:"daily"`
+	Monthly        string `json:"monthly"`
+}
+
+type tierRequest struct {
+	Tier string `json:"tier"`
+}
+
+func (a *AccountService) respondLimits(c *gin.Context, accountId string) {
+	usages, err := a.LimitModel.Usage(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": accountId,
+		"limits":     usages,
+		"status":     200,
+	})
+}
+
+// CheckAccountLimits returns the caller's limits, what was used of them and what remains.
+func (a *AccountService) CheckAccountLimits(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	a.respondLimits(c, accountId)
+}
+
+func (a *AccountService) GetAccountLimits(c *gin.Context) {
+	a.respondLimits(c, c.Param("account_id"))
+}
+
+// SetAccountLimits overrides the tier limits of one transaction type of an account.
+func (a *AccountService) SetAccountLimits(c *gin.Context) {
+	accountId := c.Param("account_id")
+	txType := c.Param("type")
+
+	if _, err := parseListParam(txType, transactionTypes, "type"); err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request limitsRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	balance, err := a.AccountModel.GetAccountBalance(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	limit := config.LimitConfig{PerTransaction: request.PerTransaction, Daily: request.Daily, Monthly: request.Monthly}
+	perTransaction, daily, monthly, err := limit.Parse(balance.Currency)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	override := model.AccountLimit{
+		AccountId: accountId,
+		Type:      txType,
+		Currency:  balance.Currency,
+		UpdatedBy: principal.UserId,
+	}
+	if perTransaction != nil {
+		override.PerTransactionUnits = &perTransaction.Units
+	}
+	if daily != nil {
+		override.DailyUnits = &daily.Units
+	}
+	if monthly != nil {
+		override.MonthlyUnits = &monthly.Units
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		previous, err := s.LimitModel.Override(accountId, txType)
+		if err != nil {
+			return nil, err
+		}
+		err = s.LimitModel.SetOverride(&override)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditAccountLimits, "account", accountId, previous, override, txType)}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	a.respondLimits(c, accountId)
+}
+
+// DeleteAccountLimits puts a transaction type of an account back on the limits of its tier.
+func (a *AccountService) DeleteAccountLimits(c *gin.Context) {
+	accountId := c.Param("account_id")
+	txType := c.Param("type")
+
+	err := a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		previous, err := s.LimitModel.Override(accountId, txType)
+		if err != nil {
+			return nil, err
+		}
+		err = s.LimitModel.DeleteOverride(accountId, txType)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditAccountLimits, "account", accountId, previous, nil, txType)}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	a.respondLimits(c, accountId)
+}
+
+func (a *AccountService) ChangeAccountTier(c *gin.Context) {
+	accountId := c.Param("account_id")
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request tierRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		previous, err := s.AccountModel.GetAccount(accountId)
+		if err != nil {
+			return nil, err
+		}
+		err = s.AccountModel.SetAccountTier(accountId, request.Tier)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditAccountTier, "account", accountId, previous.Tier, request.Tier, "")}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	a.respondLimits(c, accountId)
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reversalRequest holds the reversed amount, the rest of the transaction when zero. Force lets
+// the reversal take an account below its floor when it already spent the funds.
+type reversalRequest struct {
+	Amount money.Money `json:"amount"`
+	Reason string      `json:"reason"`
+	Force  bool        `json:"force"`
+}
+
+// ReverseTransaction queues a Reversal moving back all or part of a completed transaction.
+func (a *AccountService) ReverseTransaction(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request reversalRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		c.JSON(500, gin.H{
+			"messages": errors.New("reason must not be blank").Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	original, err := a.TransactionModel.GetTransaction(c.Param("transaction_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	amount := request.Amount
+	if amount.IsZero() {
+		reversed, err := a.TransactionModel.ReversedAmount(original)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": err.Error(),
+				"status":   500,
+			})
+			return
+		}
+		amount = money.New(original.Amount.Units-reversed.Units, original.Amount.Currency)
+		if amount.IsZero() {
+			c.JSON(500, gin.H{
+				"messages": errors.New("transaction is already fully reversed").Error(),
+				"status":   500,
+			})
+			return
+		}
+	}
+
+	reversal, err := model.NewReversal(original, amount, request.Reason, request.Force)
+	if err == nil {
+		err = a.checkValidTransaction(reversal)
+	}
+	if err == nil {
+		err = a.checkAccountStates(reversal)
+	}
+	if err == nil && !request.Force {
+		err = a.checkReversalFunds(reversal)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	reversal.RequestId = utils.RequestId(c)
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		err := s.TransactionModel.SaveReversal(reversal)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{{
+			Event:      model.AuditTransactionReversal,
+			TargetType: "transaction",
+			TargetId:   original.TransactionId,
+			After: model.AuditValue(map[string]interface{}{
+				"reversal_id": reversal.TransactionId,
+				"amount":      reversal.Amount,
+				"force":       reversal.Forced,
+			}),
+			Detail: request.Reason,
+		}}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.queueTransaction(reversal)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages":       err.Error(),
+			"transaction_id": reversal.TransactionId,
+			"status":         500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":       "your reversal request is processing !",
+		"transaction_id": reversal.TransactionId,
+		"status":         200,
+	})
+}
+
+// checkReversalFunds refuses early a reversal the account it debits can no longer pay ; the
+// worker checks again before posting.
+func (a *AccountService) checkReversalFunds(reversal *model.Transaction) error {
+	if model.IsInternalAccount(reversal.Sender) {
+		return nil
+	}
+
+	funds, err := a.AccountModel.GetFunds(reversal.Sender)
+	if err != nil {
+		return err
+	}
+	if reversal.Amount.Units > funds.Available.Units {
+		return fmt.Errorf("account %s only has %s available, set force to take it below its floor", reversal.Sender, funds.Available)
+	}
+	return nil
+}
+
+// GetTransactionReversals returns a transaction with its reversals and the amount reversed.
+func (a *AccountService) GetTransactionReversals(c *gin.Context) {
+	original, err := a.TransactionModel.GetTransaction(c.Param("transaction_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	reversals, err := a.TransactionModel.Reversals(original.TransactionId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	reversed, err := a.TransactionModel.ReversedAmount(original)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"transaction": original,
+		"reversals":   reversals,
+		"reversed":    reversed,
+		"status":      200,
+	})
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+type riskCaseDecisionRequest struct {
+	Decision string `json:"decision"`
+	Note     string `json:"note"`
+}
+
+// ListRiskCases returns the risk cases in the state given by ?state=, the open ones by default.
+func (a *AccountService) ListRiskCases(c *gin.Context) {
+	state := c.DefaultQuery("state", model.RiskCaseOpen)
+
+	cases, err := a.RiskModel.Cases(state)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"cases":  cases,
+		"status": 200,
+	})
+}
+
+// DecideRiskCase clears or declines an open risk case ; a cleared transaction is queued again and
+// posted without running the risk rules.
+func (a *AccountService) DecideRiskCase(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request riskCaseDecisionRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var tx *model.Transaction
+	var ready bool
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		tx, ready, err = s.RiskModel.Decide(c.Param("case_id"), principal.UserId, request.Decision, request.Note)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{changeEntry(model.AuditRiskCaseDecision, "risk_case", c.Param("case_id"), model.RiskCaseOpen, request.Decision, request.Note)}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if ready {
+		err = a.queueTransaction(tx)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages":       err.Error(),
+				"transaction_id": tx.TransactionId,
+				"status":         500,
+			})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"messages":       "Decision recorded !",
+		"transaction_id": tx.TransactionId,
+		"state":          tx.State,
+		"status":         200,
+	})
+}
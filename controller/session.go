@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"account-management/model"
+	re "account-management/redis"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token.
+// Replaying a refresh token that was already exchanged revokes the whole login.
+func (a *AccountService) RefreshToken(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request refreshRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if request.RefreshToken == "" {
+		c.JSON(500, gin.H{
+			"messages": errors.New("refresh_token must not be blank").Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var (
+		session      *model.Session
+		refreshToken string
+		token        string
+		reused       bool
+	)
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		var err error
+		session, refreshToken, err = s.SessionModel.Rotate(request.RefreshToken, utils.RefreshTokenLifespan())
+		if errors.Is(err, model.ErrRefreshTokenReused) {
+			// the revocation of the family is committed with its entry
+			reused = true
+			return []*model.AuditEntry{{
+				Event:      model.AuditRefreshTokenReused,
+				ActorId:    session.UserId,
+				TargetType: "session",
+				TargetId:   session.FamilyId,
+				Detail:     "the whole login was revoked",
+			}}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// the role is read again so that a role change applies from the next refresh
+		role, err := s.UserModel.GetUserRole(session.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		token, err = utils.GenerateToken(session.UserId, session.FamilyId, role)
+		if err != nil {
+			return nil, errors.New("fail to generate jwt-token")
+		}
+		return []*model.AuditEntry{tokenIssuedEntry(session.UserId, role, session.FamilyId, "refresh")}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if reused {
+		// the access tokens of the stolen family must stop working too
+		err = re.RevokeSession(a.RedisClient, session.FamilyId, utils.TokenLifespan())
+		if err == nil {
+			err = model.ErrRefreshTokenReused
+		}
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":      "Token refreshed !",
+		"status":        200,
+		"token":         token,
+		"expires_in":    int64(utils.TokenLifespan().Seconds()),
+		"refresh_token": refreshToken,
+	})
+}
+
+// Logout ends the session of the calling device : its refresh tokens and access tokens.
+func (a *AccountService) Logout(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		err := re.RevokeToken(s.RedisClient, principal.TokenId, utils.TokenLifespan())
+		if err == nil && principal.SessionId != "" {
+			err = re.RevokeSession(s.RedisClient, principal.SessionId, utils.TokenLifespan())
+			if err == nil {
+				err = s.SessionModel.RevokeFamily(principal.SessionId)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{{
+			Event:      model.AuditLogout,
+			TargetType: "session",
+			TargetId:   principal.SessionId,
+		}}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Logged out !",
+		"status":   200,
+	})
+}
+
+// LogoutAll ends every session of the caller, on every device.
+func (a *AccountService) LogoutAll(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.auditedTransaction(c, func(s *AccountService) ([]*model.AuditEntry, error) {
+		err := re.RevokeUser(s.RedisClient, principal.UserId, utils.TokenLifespan())
+		if err == nil {
+			err = s.SessionModel.RevokeUser(principal.UserId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*model.AuditEntry{{
+			Event:      model.AuditLogout,
+			TargetType: "user",
+			TargetId:   principal.UserId,
+			Detail:     "every session",
+		}}, nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Logged out from every device !",
+		"status":   200,
+	})
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type standingOrderRequest struct {
+	AccountId   string      `json:"account_id"`
+	Receiver    string      `json:"receiver"`
+	Amount      money.Money `json:"amount"`
+	Schedule    string      `json:"schedule"`
+	DayOfMonth  int         `json:"day_of_month"`
+	StartDate   string      `json:"start_date"`
+	EndDate     string      `json:"end_date"`
+	Description string      `json:"description"`
+}
+
+// CreateStandingOrder schedules transfers from the caller to the receiver (a username).
+func (a *AccountService) CreateStandingOrder(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request standingOrderRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accountId, err := a.operatedAccount(c, request.AccountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.checkValidTransaction(&model.Transaction{Type: "Transfer", Receiver: request.Receiver, Amount: request.Amount})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	receiver, err := a.AccountModel.GetAccountIdByUserName(request.Receiver)
+	if receiver == "" || err != nil {
+		c.JSON(500, gin.H{
+			"messages": errors.New("receiver doesn't exist, make sure you pass a right username").Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if receiver == accountId {
+		c.JSON(500, gin.H{
+			"messages": errors.New("receiver can't be sender").Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	order := model.StandingOrder{
+		CreatedBy:   principal.UserId,
+		AccountId:   accountId,
+		Receiver:    receiver,
+		Amount:      request.Amount,
+		Schedule:    request.Schedule,
+		DayOfMonth:  request.DayOfMonth,
+		Description: request.Description,
+	}
+
+	order.StartDate, err = time.Parse("2006-01-02", request.StartDate)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": errors.New("start_date must be a date (2006-01-02)").Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if request.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", request.EndDate)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": errors.New("end_date must be a date (2006-01-02)").Error(),
+				"status":   500,
+			})
+			return
+		}
+		order.EndDate = &endDate
+	}
+
+	err = a.StandingOrderModel.Create(&order)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":       "Created successfully !",
+		"standing_order": order,
+		"status":         200,
+	})
+}
+
+func (a *AccountService) ListStandingOrders(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	orders, err := a.StandingOrderModel.ListByAccount(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"standing_orders": orders,
+		"status":          200,
+	})
+}
+
+// callerStandingOrder returns the standing order standingOrderId if it is on an account the
+// caller holds, and operates when operate is set.
+func (a *AccountService) callerStandingOrder(c *gin.Context, standingOrderId string, operate bool) (*model.StandingOrder, error) {
+	order, err := a.StandingOrderModel.Get(standingOrderId)
+	if err != nil {
+		return nil, err
+	}
+	check := a.callerAccount
+	if operate {
+		check = a.operatedAccount
+	}
+	if _, err := check(c, order.AccountId); err != nil {
+		return nil, model.ErrStandingOrderNotFound
+	}
+	return order, nil
+}
+
+func (a *AccountService) CancelStandingOrder(c *gin.Context) {
+	order, err := a.callerStandingOrder(c, c.Param("standing_order_id"), true)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.StandingOrderModel.Cancel(order.StandingOrderId, order.AccountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Cancelled successfully !",
+		"status":   200,
+	})
+}
+
+// GetStandingOrderExecutions returns every run of one of the caller's standing orders.
+func (a *AccountService) GetStandingOrderExecutions(c *gin.Context) {
+	order, err := a.callerStandingOrder(c, c.Param("standing_order_id"), false)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	executions, err := a.StandingOrderModel.Executions(order.StandingOrderId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"standing_order_id": order.StandingOrderId,
+		"executions":        executions,
+		"status":            200,
+	})
+}
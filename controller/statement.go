@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"account-management/statement"
+	"bytes"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStatement renders the caller's statement for the period [from, to] as csv, ofx or camt053.
+func (a *AccountService) GetStatement(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", statement.FormatCSV)
+	err = statement.Validate(format)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if c.Query("from") == "" || c.Query("to") == "" {
+		c.JSON(500, gin.H{
+			"messages": "from and to are required",
+			"status":   500,
+		})
+		return
+	}
+
+	from, err := parseTime("from", c.Query("from"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	to, err := parseEndTime("to", c.Query("to"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	s, err := a.LedgerModel.Statement(accountId, from, to)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	err = statement.Render(&buf, s, format)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statement.FileName(s, format)))
+	c.Data(200, statement.ContentType(format), buf.Bytes())
+}
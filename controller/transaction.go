@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+var transactionTypes = []string{"Deposit", "Withdraw", "Transfer", "Interest", "OverdraftInterest", "Reversal"}
+
+// parseTime accepts RFC 3339 timestamps and plain dates (midnight UTC).
+func parseTime(name, value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be a date (2006-01-02) or a RFC 3339 timestamp", name)
+}
+
+// parseEndTime is parseTime for the end of a period : a plain date includes the whole day.
+func parseEndTime(name, value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	return parseTime(name, value)
+}
+
+func parseListParam(value string, allowed []string, name string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		found := false
+		for _, a := range allowed {
+			if strings.EqualFold(a, v) {
+				values = append(values, a)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s must be one of %s", name, strings.Join(allowed, ", "))
+		}
+	}
+	return values, nil
+}
+
+// parseHistoryFilter reads the query parameters of the history endpoints. Amounts are decimals
+// in the currency of the account.
+func parseHistoryFilter(c *gin.Context, currency string) (model.HistoryFilter, error) {
+	filter := model.HistoryFilter{
+		Limit:  defaultHistoryLimit,
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+	filter.Types, err = parseListParam(c.Query("type"), transactionTypes, "type")
+	if err != nil {
+		return filter, err
+	}
+
+	filter.States, err = parseListParam(c.Query("state"),
+		[]string{model.TransactionAwaitingApproval, model.TransactionUnderReview, model.TransactionPending, model.TransactionCompleted, model.TransactionRejected, model.TransactionFailed}, "state")
+	if err != nil {
+		return filter, err
+	}
+
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = parseTime("from", value); err != nil {
+			return filter, err
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = parseEndTime("to", value); err != nil {
+			return filter, err
+		}
+	}
+
+	if value := c.Query("min_amount"); value != "" {
+		amount, err := money.Parse(value, currency)
+		if err != nil {
+			return filter, fmt.Errorf("min_amount : %v", err)
+		}
+		filter.MinAmount = &amount.Units
+	}
+	if value := c.Query("max_amount"); value != "" {
+		amount, err := money.Parse(value, currency)
+		if err != nil {
+			return filter, fmt.Errorf("max_amount : %v", err)
+		}
+		filter.MaxAmount = &amount.Units
+	}
+
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxHistoryLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// respondHistory writes one page of the transaction history of accountId.
+func (a *AccountService) respondHistory(c *gin.Context, accountId string) {
+	balance, err := a.AccountModel.GetAccountBalance(accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	filter, err := parseHistoryFilter(c, balance.Currency)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	entries, nextCursor, err := a.TransactionModel.History(accountId, filter)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"account_id":   accountId,
+		"transactions": entries,
+		"next_cursor":  nextCursor,
+		"status":       200,
+	})
+}
+
+// ListTransactions returns the caller's deposits, withdrawals and transfers, sent and received.
+func (a *AccountService) ListTransactions(c *gin.Context) {
+	accountId, err := a.callerAccount(c, c.Query("account_id"))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	a.respondHistory(c, accountId)
+}
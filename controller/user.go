@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accountSelection holds the accounts a request of a user names : AccountId, the account the
+// request acts on (the default account when blank), and ReceiverAccountId, another account of
+// the user receiving a transfer.
+type accountSelection struct {
+	AccountId         string `json:"account_id"`
+	ReceiverAccountId string `json:"receiver_account_id"`
+}
+
+type openAccountRequest struct {
+	Product string `json:"product"`
+	Name    string `json:"name"`
+}
+
+// callerAccount returns accountId if the caller holds it, the caller's default account when
+// accountId is blank.
+func (a *AccountService) callerAccount(c *gin.Context, accountId string) (string, error) {
+	accountId, _, err := a.holderAccount(c, accountId)
+	return accountId, err
+}
+
+// operatedAccount is callerAccount for requests moving money, which viewers may not make.
+func (a *AccountService) operatedAccount(c *gin.Context, accountId string) (string, error) {
+	accountId, role, err := a.holderAccount(c, accountId)
+	if err == nil && !model.CanOperate(role) {
+		err = errors.New("viewers can't operate this account")
+	}
+	return accountId, err
+}
+
+// ownedAccount is callerAccount for requests managing the holders and policies of the account.
+func (a *AccountService) ownedAccount(c *gin.Context, accountId string) (string, error) {
+	accountId, role, err := a.holderAccount(c, accountId)
+	if err == nil && role != model.HolderOwner {
+		err = errors.New("only owners can manage this account")
+	}
+	return accountId, err
+}
+
+// holderAccount returns accountId, or the caller's default account when blank, with the role of
+// the caller on it.
+func (a *AccountService) holderAccount(c *gin.Context, accountId string) (string, string, error) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		return "", "", err
+	}
+
+	if accountId == "" {
+		accountId, err = a.UserModel.GetDefaultAccountId(principal.UserId)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	role, err := a.AccountModel.HolderRole(accountId, principal.UserId)
+	if err != nil {
+		return "", "", err
+	}
+	return accountId, role, nil
+}
+
+// ListAccounts returns the accounts of the caller with their balances.
+func (a *AccountService) ListAccounts(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	user, err := a.UserModel.GetUser(principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accounts, err := a.AccountModel.ListByUser(principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"accounts":           accounts,
+		"default_account_id": user.DefaultAccountId,
+		"status":             200,
+	})
+}
+
+// OpenAccount opens another account of the caller, of the product they choose.
+func (a *AccountService) OpenAccount(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request openAccountRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	account := model.Account{
+		UserId:  principal.UserId,
+		Name:    request.Name,
+		Product: request.Product,
+	}
+	if account.Product == "" {
+		account.Product = model.DefaultProduct
+	}
+
+	err = a.AccountModel.Open(&account)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Opened successfully !",
+		"account":  account,
+		"status":   200,
+	})
+}
+
+// ChangeDefaultAccount makes one of the caller's accounts the one used when a request names
+// none, and the one receiving the transfers made to their username.
+func (a *AccountService) ChangeDefaultAccount(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	accountId := c.Param("account_id")
+	err = a.UserModel.SetDefaultAccount(principal.UserId, accountId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages":           "Default account changed !",
+		"default_account_id": accountId,
+		"status":             200,
+	})
+}
@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"account-management/model"
+	"account-management/utils.go"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookRequest subscribes Url to Events, on AccountId only or, when blank, on every account the
+// caller holds.
+type webhookRequest struct {
+	Url       string   `json:"url"`
+	Events    []string `json:"events"`
+	AccountId string   `json:"account_id"`
+}
+
+// CreateWebhook subscribes an endpoint of the caller to events. The secret signing the deliveries
+// is only returned here.
+func (a *AccountService) CreateWebhook(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	var request webhookRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	if request.AccountId != "" {
+		_, err = a.callerAccount(c, request.AccountId)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": err.Error(),
+				"status":   500,
+			})
+			return
+		}
+	}
+
+	subscription := model.WebhookSubscription{
+		UserId:    principal.UserId,
+		AccountId: request.AccountId,
+		Url:       request.Url,
+		Events:    strings.Join(request.Events, ","),
+	}
+	err = a.WebhookModel.Subscribe(&subscription)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Created successfully !",
+		"webhook":  subscription,
+		"status":   200,
+	})
+}
+
+// ListWebhooks returns the subscriptions of the caller, without their secrets.
+func (a *AccountService) ListWebhooks(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	subscriptions, err := a.WebhookModel.ListByUser(principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	c.JSON(200, gin.H{
+		"webhooks": subscriptions,
+		"status":   200,
+	})
+}
+
+func (a *AccountService) DeleteWebhook(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.WebhookModel.Delete(c.Param("webhook_id"), principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Webhook deleted !",
+		"status":   200,
+	})
+}
+
+// EnableWebhook enables again a subscription disabled after failing too many times.
+func (a *AccountService) EnableWebhook(c *gin.Context) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	err = a.WebhookModel.Enable(c.Param("webhook_id"), principal.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Webhook enabled !",
+		"status":   200,
+	})
+}
+
+// callerWebhook returns the subscription of the request's :webhook_id, which must be the caller's.
+func (a *AccountService) callerWebhook(c *gin.Context) (*model.WebhookSubscription, error) {
+	principal, err := utils.CurrentPrincipal(c)
+	if err != nil {
+		return nil, err
+	}
+	return a.WebhookModel.Get(c.Param("webhook_id"), principal.UserId)
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a subscription of the caller, ?limit=
+// of them (50 by default).
+func (a *AccountService) ListWebhookDeliveries(c *gin.Context) {
+	subscription, err := a.callerWebhook(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 500 {
+			c.JSON(500, gin.H{
+				"messages": "limit must be between 1 and 500",
+				"status":   500,
+			})
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := a.WebhookModel.Deliveries(subscription.SubscriptionId, limit)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"deliveries": deliveries,
+		"status":     200,
+	})
+}
+
+// GetWebhookDelivery returns a delivery of a subscription of the caller with each of its attempts.
+func (a *AccountService) GetWebhookDelivery(c *gin.Context) {
+	subscription, err := a.callerWebhook(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	delivery, attempts, err := a.WebhookModel.GetDelivery(c.Param("delivery_id"), subscription.SubscriptionId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"delivery": delivery,
+		"attempts": attempts,
+		"status":   200,
+	})
+}
+
+// RedeliverWebhook sends a delivery that succeeded or failed again, as a new delivery of the same
+// event.
+func (a *AccountService) RedeliverWebhook(c *gin.Context) {
+	subscription, err := a.callerWebhook(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	delivery, err := a.WebhookModel.Redeliver(c.Param("delivery_id"), subscription.SubscriptionId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"messages": err.Error(),
+			"status":   500,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"messages": "Redelivery queued !",
+		"delivery": delivery,
+		"status":   200,
+	})
+}
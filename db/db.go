@@ -1,6 +1,7 @@
 package db
 
 import (
+	"account-management/config"
 	"account-management/model"
 	"fmt"
 
@@ -8,15 +9,9 @@ import (
 	"gorm.io/gorm"
 )
 
-func InitDB() *gorm.DB {
-	username := "hppoc"
-	password := "password"
-	host := "127.0.0.1"
-	port := "5432"
-	dbname := "account-management"
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", host, username, password, dbname, port)
-	fmt.Printf("PgService.NewPgService: dsn = %s\n", dsn)
+func InitDB(cfg config.DatabaseConfig) *gorm.DB {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s", cfg.Host, cfg.User, cfg.Password, cfg.Name, cfg.Port, cfg.SSLMode)
+	fmt.Printf("PgService.NewPgService: host = %s, port = %d, dbname = %s\n", cfg.Host, cfg.Port, cfg.Name)
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN:                  dsn,
 		PreferSimpleProtocol: true, // disables implicit prepared statement usage
@@ -30,8 +25,64 @@ func InitDB() *gorm.DB {
 		panic("Failed to connect database")
 	}
 
+	db.AutoMigrate(&model.User{})
 	db.AutoMigrate(&model.Account{})
 	db.AutoMigrate(&model.Transaction{})
+	db.AutoMigrate(&model.JournalEntry{})
+	db.AutoMigrate(&model.Posting{})
+	db.AutoMigrate(&model.IdempotencyKey{})
+	db.AutoMigrate(&model.Session{})
+	db.AutoMigrate(&model.AccountStateChange{})
+	db.AutoMigrate(&model.StandingOrder{})
+	db.AutoMigrate(&model.StandingOrderExecution{})
+	db.AutoMigrate(&model.AccountLimit{})
+	db.AutoMigrate(&model.InterestAccrual{})
+	db.AutoMigrate(&model.Hold{})
+	db.AutoMigrate(&model.AccountHolder{})
+	db.AutoMigrate(&model.ApprovalPolicy{})
+	db.AutoMigrate(&model.Approval{})
+	db.AutoMigrate(&model.Beneficiary{})
+	db.AutoMigrate(&model.RiskCase{})
+	db.AutoMigrate(&model.AuditEntry{})
+	db.AutoMigrate(&model.WebhookSubscription{})
+	db.AutoMigrate(&model.WebhookDelivery{})
+	db.AutoMigrate(&model.WebhookAttempt{})
+
+	if err := createIndexes(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateUsers(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateAccountHolders(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateAccountNumbers(db); err != nil {
+		panic(err)
+	}
+
+	if err := protectAuditLog(db); err != nil {
+		panic(err)
+	}
+
+	if err := dropLegacyColumns(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateLegacyAmounts(db); err != nil {
+		panic(err)
+	}
+
+	if err := model.NewTransactionModel(db).BackfillStates(); err != nil {
+		panic(err)
+	}
+
+	if err := model.NewLedgerModel(db, model.NewAccountModel(db)).BackfillOpeningBalances(); err != nil {
+		panic(err)
+	}
 
 	return db
 
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// migrateLegacyAmounts moves float columns written by older versions (accounts.balance,
+// transactions.amount) into the integer minor-unit columns of money.Money, then drops them.
+// Rows that can't be represented exactly in the default currency abort the migration.
+func migrateLegacyAmounts(db *gorm.DB) error {
+	legacyColumns := []struct {
+		model  interface{}
+		table  string
+		column string
+		prefix string
+	}{
+		{&model.Account{}, "accounts", "balance", "balance_"},
+		{&model.Transaction{}, "transactions", "amount", "amount_"},
+	}
+
+	exp, err := money.Exponent(money.DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	scale := int64(math.Pow10(exp))
+
+	for _, legacy := range legacyColumns {
+		if !db.Migrator().HasColumn(legacy.model, legacy.column) {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var invalidRows int64
+			err := tx.Raw(fmt.Sprintf("select count(*) from %s where %s is not null and %s::numeric * ? <> trunc(%s::numeric * ?)",
+				legacy.table, legacy.column, legacy.column, legacy.column), scale, scale).Scan(&invalidRows).Error
+			if err != nil {
+				return err
+			}
+			if invalidRows > 0 {
+				return fmt.Errorf("%d rows in %s.%s have more precision than %s allows (%d decimal places)",
+					invalidRows, legacy.table, legacy.column, money.DefaultCurrency, exp)
+			}
+
+			err = tx.Exec(fmt.Sprintf("update %s set %sunits = (%s::numeric * ?)::bigint, %scurrency = ? where %s is not null",
+				legacy.table, legacy.prefix, legacy.column, legacy.prefix, legacy.column), scale, money.DefaultCurrency).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Migrator().DropColumn(legacy.model, legacy.column)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s.%s : %v", legacy.table, legacy.column, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateUsers moves the logins stored on accounts by older versions (accounts.username,
+// password and role) into users. Each user takes the id of its account, which becomes its
+// default account, so that issued tokens and sessions, whose subject was the account id, stay
+// valid.
+func migrateUsers(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&model.Account{}, "username") {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// only the versions with roles stored them on accounts
+			role := "?"
+			if tx.Migrator().HasColumn(&model.Account{}, "role") {
+				role = "coalesce(nullif(role, ''), ?)"
+			}
+			err := tx.Exec(`insert into users (user_id, username, password, role, default_account_id, created_time)
+				select account_id, username, password, `+role+`, account_id, created_time from accounts
+				on conflict do nothing`, model.RoleCustomer).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec("update accounts set user_id = account_id where user_id is null or user_id = ''").Error
+			if err != nil {
+				return err
+			}
+
+			for _, column := range []string{"username", "password", "role"} {
+				if !tx.Migrator().HasColumn(&model.Account{}, column) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&model.Account{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate accounts to users : %v", err)
+		}
+	}
+
+	if db.Migrator().HasColumn(&model.Session{}, "account_id") {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Exec("update sessions set user_id = account_id where user_id is null or user_id = ''").Error
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&model.Session{}, "account_id")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate sessions to users : %v", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateAccountHolders makes the user of each account its owner, for accounts opened before
+// accounts could have several holders.
+func migrateAccountHolders(db *gorm.DB) error {
+	err := db.Exec(`insert into account_holders (account_id, user_id, role, created_time)
+		select account_id, user_id, ?, created_time from accounts where user_id <> ''
+		on conflict do nothing`, model.HolderOwner).Error
+	if err != nil {
+		return fmt.Errorf("failed to migrate account holders : %v", err)
+	}
+	return nil
+}
+
+// migrateAccountNumbers numbers the accounts opened before accounts had numbers.
+func migrateAccountNumbers(db *gorm.DB) error {
+	var accountIds []string
+	err := db.Raw("select account_id from accounts where account_number is null or account_number = ''").Scan(&accountIds).Error
+	if err != nil {
+		return fmt.Errorf("failed to migrate account numbers : %v", err)
+	}
+
+	for _, accountId := range accountIds {
+		number, err := model.NewAccountNumber()
+		if err != nil {
+			return err
+		}
+		err = db.Exec("update accounts set account_number = ? where account_id = ?", number, accountId).Error
+		if err != nil {
+			return fmt.Errorf("failed to migrate account numbers : %v", err)
+		}
+	}
+	return nil
+}
+
+// protectAuditLog makes the database refuse updates and deletes of audit entries. Whoever can
+// drop the triggers can still edit the log, which the hash chain then reveals.
+func protectAuditLog(db *gorm.DB) error {
+	statements := []string{
+		`create or replace function audit_entries_append_only() returns trigger as $$
+		begin
+			raise exception 'audit_entries is append-only';
+		end;
+		$$ language plpgsql`,
+		"drop trigger if exists audit_entries_append_only on audit_entries",
+		"create trigger audit_entries_append_only before update or delete on audit_entries for each row execute procedure audit_entries_append_only()",
+		"drop trigger if exists audit_entries_no_truncate on audit_entries",
+		"create trigger audit_entries_no_truncate before truncate on audit_entries for each statement execute procedure audit_entries_append_only()",
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to protect audit log : %v", err)
+		}
+	}
+	return nil
+}
+
+// dropLegacyColumns removes columns no longer used, e.g. accounts.token which held the last
+// access token of each account before sessions existed.
+func dropLegacyColumns(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&model.Account{}, "token") {
+		if err := db.Migrator().DropColumn(&model.Account{}, "token"); err != nil {
+			return fmt.Errorf("failed to drop accounts.token : %v", err)
+		}
+	}
+	return nil
+}
+
+// createIndexes adds the indexes gorm tags can't express.
+func createIndexes(db *gorm.DB) error {
+	statements := []string{
+		// a client's Idempotency-Key maps to a single transaction, even if two requests race
+		"create unique index if not exists idx_transactions_sender_idempotency_key on transactions (sender, idempotency_key) where idempotency_key <> ''",
+		// transaction history : pages of an account's sent and received transactions by date
+		"create index if not exists idx_transactions_sender_created on transactions (sender, created_time, transaction_id)",
+		"create index if not exists idx_transactions_receiver_created on transactions (receiver, created_time, transaction_id)",
+		"create index if not exists idx_transactions_created on transactions (created_time)",
+		"create index if not exists idx_postings_entry_account on postings (entry_id, account_id)",
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create index : %v", err)
+		}
+	}
+	return nil
+}
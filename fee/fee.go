@@ -0,0 +1,172 @@
+package fee
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Band prices the amounts up to UpTo (inclusive), or all the remaining amounts when UpTo is blank.
+type Band struct {
+	UpTo       string `yaml:"up_to"`
+	Flat       string `yaml:"flat"`
+	Percentage string `yaml:"percentage"`
+}
+
+// Rule prices one transaction type, for one account tier or every tier when Tier is blank.
+// The fee is Flat plus Percentage of the amount, or those of the first band the amount falls
+// in, bounded by Min and Max. The first FreePerMonth transactions of each month are free.
+// Amounts are decimal strings in the currency of the transaction.
+type Rule struct {
+	Type         string `yaml:"type"`
+	Tier         string `yaml:"tier"`
+	Flat         string `yaml:"flat"`
+	Percentage   string `yaml:"percentage"`
+	Bands        []Band `yaml:"bands"`
+	Min          string `yaml:"min"`
+	Max          string `yaml:"max"`
+	FreePerMonth int    `yaml:"free_per_month"`
+}
+
+// Schedule is an ordered list of rules ; the first matching rule applies.
+type Schedule []Rule
+
+func (s Schedule) Find(txType, tier string) *Rule {
+	for i := range s {
+		if s[i].Type == txType && (s[i].Tier == "" || s[i].Tier == tier) {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+func parseAmount(name, amount, currency string) (*money.Money, error) {
+	if amount == "" {
+		return nil, nil
+	}
+	m, err := money.Parse(amount, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %v", name, err)
+	}
+	if m.IsNegative() {
+		return nil, fmt.Errorf("%s must not be negative", name)
+	}
+	return &m, nil
+}
+
+func parsePercentage(percentage string) (*big.Rat, error) {
+	if percentage == "" {
+		return new(big.Rat), nil
+	}
+	r, ok := new(big.Rat).SetString(percentage)
+	if !ok || r.Sign() < 0 || strings.ContainsAny(percentage, "/eE") {
+		return nil, fmt.Errorf("percentage must be a positive decimal number : %s", percentage)
+	}
+	return r, nil
+}
+
+// Validate checks that the amounts of the rule can be read in currency.
+func (r *Rule) Validate(currency string) error {
+	if r.Type == "" {
+		return errors.New("type must not be blank")
+	}
+	if r.FreePerMonth < 0 {
+		return errors.New("free_per_month must not be negative")
+	}
+
+	for name, amount := range map[string]string{"flat": r.Flat, "min": r.Min, "max": r.Max} {
+		if _, err := parseAmount(name, amount, currency); err != nil {
+			return err
+		}
+	}
+	if _, err := parsePercentage(r.Percentage); err != nil {
+		return err
+	}
+
+	for i, band := range r.Bands {
+		if band.UpTo == "" && i != len(r.Bands)-1 {
+			return errors.New("only the last band may have a blank up_to")
+		}
+		if _, err := parseAmount("up_to", band.UpTo, currency); err != nil {
+			return err
+		}
+		if _, err := parseAmount("flat", band.Flat, currency); err != nil {
+			return err
+		}
+		if _, err := parsePercentage(band.Percentage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// price returns flat + percentage % of amount, rounded half up to the minor unit.
+func price(amount money.Money, flat, percentage string) (money.Money, error) {
+	fee := money.New(0, amount.Currency)
+
+	f, err := parseAmount("flat", flat, amount.Currency)
+	if err != nil {
+		return fee, err
+	}
+	if f != nil {
+		fee = *f
+	}
+
+	p, err := parsePercentage(percentage)
+	if err != nil {
+		return fee, err
+	}
+
+	variable := new(big.Rat).Mul(new(big.Rat).SetInt64(amount.Units), p)
+	variable.Quo(variable, big.NewRat(100, 1))
+	variable.Add(variable, big.NewRat(1, 2))
+	units := new(big.Int).Quo(variable.Num(), variable.Denom())
+
+	fee.Units += units.Int64()
+	return fee, nil
+}
+
+// Compute returns the fee of a transaction of amount when usedThisMonth transactions of the
+// same type were already made this month.
+func (r *Rule) Compute(amount money.Money, usedThisMonth int) (money.Money, error) {
+	if usedThisMonth < r.FreePerMonth {
+		return money.New(0, amount.Currency), nil
+	}
+
+	flat, percentage := r.Flat, r.Percentage
+	for _, band := range r.Bands {
+		upTo, err := parseAmount("up_to", band.UpTo, amount.Currency)
+		if err != nil {
+			return money.Money{}, err
+		}
+		if upTo == nil || amount.Units <= upTo.Units {
+			flat, percentage = band.Flat, band.Percentage
+			break
+		}
+	}
+
+	fee, err := price(amount, flat, percentage)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	min, err := parseAmount("min", r.Min, amount.Currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if min != nil && fee.Units < min.Units {
+		fee = *min
+	}
+
+	max, err := parseAmount("max", r.Max, amount.Currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if max != nil && fee.Units > max.Units {
+		fee = *max
+	}
+
+	return fee, nil
+}
@@ -0,0 +1,91 @@
+package fee
+
+import (
+	"account-management/money"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	banded := Rule{
+		Type: "Transfer",
+		Bands: []Band{
+			{UpTo: "100.00", Flat: "0.50"},
+			{UpTo: "1000.00", Percentage: "1"},
+			{Flat: "2.00", Percentage: "0.5"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		rule   Rule
+		amount int64
+		used   int
+		want   int64
+	}{
+		{"flat", Rule{Flat: "1.00"}, 5000, 0, 100},
+		{"percentage", Rule{Percentage: "1.5"}, 10000, 0, 150},
+		{"percentage rounds half up", Rule{Percentage: "1"}, 150, 0, 2},
+		{"percentage rounds down below half", Rule{Percentage: "1"}, 149, 0, 1},
+		{"flat and percentage", Rule{Flat: "0.25", Percentage: "2"}, 1000, 0, 45},
+		{"min", Rule{Percentage: "1", Min: "1.00"}, 1000, 0, 100},
+		{"max", Rule{Percentage: "10", Max: "5.00"}, 100000, 0, 500},
+		{"free allowance", Rule{Flat: "1.00", FreePerMonth: 3}, 5000, 2, 0},
+		{"after the free allowance", Rule{Flat: "1.00", FreePerMonth: 3}, 5000, 3, 100},
+		{"first band, inclusive", banded, 10000, 0, 50},
+		{"second band", banded, 50000, 0, 500},
+		{"last band", banded, 200000, 0, 1200},
+		{"no fee", Rule{}, 5000, 0, 0},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.rule.Compute(money.New(tt.amount, "USD"), tt.used)
+		if err != nil {
+			t.Errorf("%s : %v", tt.name, err)
+			continue
+		}
+		if got.Units != tt.want || got.Currency != "USD" {
+			t.Errorf("%s : fee of %d = %v, want %d USD units", tt.name, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"valid", Rule{Type: "Withdraw", Flat: "1.00", Percentage: "0.5", Min: "0.10", Max: "10"}, false},
+		{"blank type", Rule{Flat: "1.00"}, true},
+		{"negative free allowance", Rule{Type: "Withdraw", FreePerMonth: -1}, true},
+		{"too precise", Rule{Type: "Withdraw", Flat: "0.001"}, true},
+		{"negative flat", Rule{Type: "Withdraw", Flat: "-1"}, true},
+		{"fraction percentage", Rule{Type: "Withdraw", Percentage: "1/2"}, true},
+		{"negative percentage", Rule{Type: "Withdraw", Percentage: "-1"}, true},
+		{"open band not last", Rule{Type: "Withdraw", Bands: []Band{{Flat: "1"}, {UpTo: "10", Flat: "2"}}}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.rule.Validate("USD")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s : Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	schedule := Schedule{
+		{Type: "Withdraw", Tier: "premium", Flat: "0"},
+		{Type: "Withdraw", Flat: "1"},
+	}
+
+	if rule := schedule.Find("Withdraw", "premium"); rule == nil || rule.Tier != "premium" {
+		t.Errorf("Find(premium) = %v, want the premium rule", rule)
+	}
+	if rule := schedule.Find("Withdraw", "standard"); rule == nil || rule.Tier != "" {
+		t.Errorf("Find(standard) = %v, want the rule of every tier", rule)
+	}
+	if rule := schedule.Find("Transfer", "standard"); rule != nil {
+		t.Errorf("Find(Transfer) = %v, want none", rule)
+	}
+}
@@ -1,14 +1,53 @@
 package middlewares
 
 import (
+	"account-management/model"
+	re "account-management/redis"
 	"account-management/utils.go"
+	"errors"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
 )
 
-func JwtAuthMiddleware() gin.HandlerFunc {
+// RequestIdMiddleware gives every request an id, the client's X-Request-Id when it is usable,
+// which the audit log records and the response echoes.
+func RequestIdMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := utils.TokenValid(c)
+		requestId := c.GetHeader(utils.RequestIdHeader)
+		if !isPrintable(requestId) || len(requestId) > 128 {
+			requestId = uuid.NewString()
+		}
+		utils.SetRequestId(c, requestId)
+		c.Header(utils.RequestIdHeader, requestId)
+		c.Next()
+	}
+}
+
+func isPrintable(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func JwtAuthMiddleware(rdb *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := utils.ParseToken(utils.ExtractToken(c))
+		if err == nil {
+			var revoked bool
+			revoked, err = re.IsRevoked(rdb, principal)
+			if err == nil && revoked {
+				err = errors.New("token has been revoked")
+			}
+		}
 		if err != nil {
 			c.JSON(500, gin.H{
 				"messages": "Unauthorized",
@@ -18,6 +57,28 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		utils.SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// RequirePermission lets the request through only when the role of the authenticated caller
+// grants permission. It must run after JwtAuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := utils.CurrentPrincipal(c)
+		if err == nil && !model.HasPermission(principal.Role, permission) {
+			err = errors.New("missing permission " + permission)
+		}
+		if err != nil {
+			c.JSON(500, gin.H{
+				"messages": "Forbidden",
+				"error":    err.Error(),
+				"status":   500,
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
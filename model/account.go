@@ -1,6 +1,7 @@
 package model
 
 import (
+	"account-management/money"
 	"errors"
 	"fmt"
 	"time"
@@ -10,18 +11,58 @@ import (
 )
 
 var (
-	initBalance    float64 = 50000
-	minimumBalance float64 = 50000
+	initBalance    = money.New(50000, money.DefaultCurrency)
+	minimumBalance = money.New(50000, money.DefaultCurrency)
 )
 
+// requireVerification makes new accounts start PENDING_VERIFICATION instead of ACTIVE.
+var requireVerification = false
+
+func SetRequireVerification(required bool) {
+	requireVerification = required
+}
+
+// SetBalanceRules sets the balance granted to new accounts and the legacy floor, the balance the
+// accounts opened before per-account floors must keep until an admin sets their own.
+func SetBalanceRules(initial, minimum money.Money) {
+	initBalance = initial
+	minimumBalance = minimum
+}
+
+// Account holds money of the user UserId, who opened it ; a user may have several, e.g. a
+// current and a savings account, and share them with other holders (see AccountHolder).
 type Account struct {
-	AccountId   string `gorm:"primaryKey"`
-	Username    string `gorm:"unique"`
-	Password    string
+	AccountId string `gorm:"primaryKey"`
+	UserId    string `gorm:"index"`
+	// AccountNumber is the number given to payers, see NewAccountNumber.
+	AccountNumber string `gorm:"uniqueIndex"`
+	// Name is a label chosen by the user, e.g. "Holidays".
+	Name        string
 	CreatedTime time.Time
-	Balance     float64
-	State       int32
-	Token       string
+	Balance     money.Money `gorm:"embedded;embeddedPrefix:balance_"`
+	State       AccountState
+	// Tier selects the default transaction limits of the account.
+	Tier string `gorm:"default:standard"`
+	// Product selects the interest rate table of the account.
+	Product string `gorm:"default:current"`
+	// FloorUnits is the lowest balance withdrawals and transfers may leave, in the currency of the
+	// balance ; negative for an overdraft. Accounts open with a floor of zero, nil is left on the
+	// accounts opened before floors, which keep the legacy minimum balance.
+	FloorUnits *int64
+}
+
+// Funds tells how much an account can spend : its balance, less the funds reserved by active
+// holds, down to its floor. OverdraftLimit is the part of the floor below zero.
+type Funds struct {
+	Balance money.Money
+	Held    money.Money
+	// AvailableBalance is the balance less the holds.
+	AvailableBalance   money.Money
+	Floor              money.Money
+	Available          money.Money
+	OverdraftLimit     money.Money
+	OverdraftUsed      money.Money
+	OverdraftRemaining money.Money
 }
 
 type AccountModel struct {
@@ -36,70 +77,113 @@ func NewAccountModel(db *gorm.DB) *AccountModel {
 	}
 }
 
-func (a *AccountModel) Register(account *Account) error {
-	if a.UserNameExist(account.Username) {
-		return errors.New("username existed")
-	}
-
+// openAccount creates account, of account.UserId and account.Product, with an opening entry
+// granting it balance and a floor of zero ; account.UserId becomes its owner. The account starts
+// PENDING_VERIFICATION when verification is required.
+func openAccount(tx *gorm.DB, account *Account, balance money.Money) error {
 	account.CreatedTime = time.Now()
 	account.AccountId = uuid.NewString()
-	account.Balance = initBalance
-
-	err := a.DB.Create(account).Error
+	number, err := NewAccountNumber()
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-// func (a *AccountModel) CheckValidLogin(username string, password string) error {
+	account.AccountNumber = number
+	account.Balance = balance
+	floor := int64(0)
+	account.FloorUnits = &floor
+	account.Tier = DefaultTier
+	account.State = AccountActive
+	if requireVerification {
+		account.State = AccountPendingVerification
+	}
 
-// 	return nil
-// }
+	err = tx.Create(account).Error
+	if err != nil {
+		return fmt.Errorf("failed to save account : %v", err)
+	}
 
-func (a *AccountModel) UserNameExist(username string) bool {
-	var usernameExist string
-	err := a.DB.Raw("select username from accounts where username = ?", username).Scan(&usernameExist).Error
+	err = tx.Create(&AccountHolder{
+		AccountId:   account.AccountId,
+		UserId:      account.UserId,
+		Role:        HolderOwner,
+		CreatedTime: account.CreatedTime,
+	}).Error
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to save account holder : %v", err)
 	}
 
-	if usernameExist != "" {
-		return true
+	if balance.IsZero() {
+		return nil
+	}
+	units := balance.Units
+	entry := &JournalEntry{Description: "Opening balance"}
+	postings := []Posting{
+		{AccountId: account.AccountId, Amount: balance, BalanceAfter: &units},
+		{AccountId: OpeningBalanceAccountId, Amount: balance.Neg()},
 	}
-	return false
+	if err := recordJournal(tx, entry, postings); err != nil {
+		return err
+	}
+	return auditBalanceChanges(tx, entry, postings)
 }
 
-func (a *AccountModel) GetHashedPasswordByUsername(username string) string {
-	var hashedPassword string
-	err := a.DB.Raw("select password from accounts where username = ?", username).Scan(&hashedPassword).Error
-	if err != nil {
-		return ""
+// Open creates another account of account.UserId, of the product account.Product, with a zero
+// balance.
+func (a *AccountModel) Open(account *Account) error {
+	if !IsValidProduct(account.Product) {
+		return fmt.Errorf("unknown product : %s", account.Product)
 	}
-	return hashedPassword
+
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		return openAccount(tx, account, money.New(0, initBalance.Currency))
+	})
 }
 
-func (a *AccountModel) GetAccountIdByToken(token string) (string, error) {
+// GetAccountIdByUserName returns the default account of the user username, the account that
+// receives the transfers made to that username.
+func (a *AccountModel) GetAccountIdByUserName(username string) (string, error) {
 	var accountId string
-	err := a.DB.Raw("select account_id from accounts where token = ?", token).Scan(&accountId).Error
+	err := a.DB.Raw("select default_account_id from users where username = ?", username).Scan(&accountId).Error
 	if err != nil {
 		return "", err
 	}
 	return accountId, nil
 }
 
-func (a *AccountModel) GetAccountIdByUserName(username string) (string, error) {
-	var accountId string
-	err := a.DB.Raw("select account_id from accounts where username = ?", username).Scan(&accountId).Error
-	if err != nil {
+// GetAccountIdByNumber returns the account numbered number, ErrInvalidAccountNumber when its check
+// digits are wrong.
+func (a *AccountModel) GetAccountIdByNumber(number string) (string, error) {
+	number = NormalizeAccountNumber(number)
+	if err := ValidateAccountNumber(number); err != nil {
 		return "", err
 	}
-	return accountId, nil
+
+	var accountIds []string
+	err := a.DB.Raw("select account_id from accounts where account_number = ?", number).Scan(&accountIds).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get account : %v", err)
+	}
+	if len(accountIds) == 0 {
+		return "", errors.New("account doesn't exist")
+	}
+	return accountIds[0], nil
+}
+
+func (a *AccountModel) GetAccount(accountId string) (*Account, error) {
+	var account Account
+	result := a.DB.Where("account_id = ?", accountId).Limit(1).Find(&account)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get account : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("account doesn't exist")
+	}
+	return &account, nil
 }
 
 func (a *AccountModel) GetList() ([]Account, error) {
 	var accounts []Account
-	err := a.DB.Table("accounts").Omit("password", "token").Find(&accounts).Error
+	err := a.DB.Table("accounts").Find(&accounts).Error
 	if err != nil {
 		return nil, err
 	}
@@ -107,80 +191,162 @@ func (a *AccountModel) GetList() ([]Account, error) {
 	return accounts, nil
 }
 
-func (a *AccountModel) SaveToken(token, username string) error {
-	err := a.DB.Exec("update accounts set token = ? where username = ?", token, username).Error
-	if err != nil {
-		return errors.New("failed to save account's token")
-	}
-	return nil
-}
-
 // func (a *AccountModel) UpdateBalance(tx *Transaction) error {
 
 // }
 
-func (a *AccountModel) GetAccountBalance(accountId string) (float64, error) {
-	var balance float64
-	err := a.DB.Raw("select balance from accounts where account_id = ?", accountId).Scan(&balance).Error
+func (a *AccountModel) GetAccountBalance(accountId string) (money.Money, error) {
+	var balance money.Money
+	err := a.DB.Raw("select balance_units as units, balance_currency as currency from accounts where account_id = ?", accountId).Scan(&balance).Error
 	if err != nil {
-		return 0, errors.New("failed to get balance's account")
+		return money.Money{}, errors.New("failed to get balance's account")
 	}
 	return balance, nil
 }
 
-func (a *AccountModel) SaveNewBalanceWithPositiveAmount(amount float64, accountId string, txs ...*gorm.DB) error {
+func (a *AccountModel) SaveNewBalanceWithPositiveAmount(amount money.Money, accountId string, txs ...*gorm.DB) error {
 	var tx = a.DB
 
 	if len(txs) > 0 {
 		tx = txs[0]
 	}
 
-	err := tx.Exec("update accounts set balance = accounts.balance + ? where account_id = ?", amount, accountId).Error
-	if err != nil {
+	result := tx.Exec("update accounts set balance_units = accounts.balance_units + ? where account_id = ? and balance_currency = ?", amount.Units, accountId, amount.Currency)
+	if err := result.Error; err != nil {
 		return fmt.Errorf("failed to save new balance : %v", err)
 	}
 
+	if result.RowsAffected == 0 {
+		return Reject(ReasonAccountNotFound, "failed to save new balance : account doesn't exist or doesn't hold %s", amount.Currency)
+	}
+
 	return nil
 }
 
-func (a *AccountModel) SaveNewBalanceWithNegativeAmount(amount float64, accountId string, txs ...*gorm.DB) error {
+// SaveNewBalanceWithNegativeAmount debits amount unless the balance after the debit, less the
+// active holds, would fall below the floor of the account.
+func (a *AccountModel) SaveNewBalanceWithNegativeAmount(amount money.Money, accountId string, txs ...*gorm.DB) error {
 	var tx = a.DB
 
 	if len(txs) > 0 {
 		tx = txs[0]
 	}
 
-	result := tx.Exec("update accounts set balance = accounts.balance - ? where account_id = ? and balance > ?", amount, accountId, minimumBalance)
+	if !amount.SameCurrency(minimumBalance) {
+		return Reject(ReasonCurrencyMismatch, "failed to save new balance : unsupported currency %s", amount.Currency)
+	}
+
+	result := tx.Exec("update accounts set balance_units = accounts.balance_units - ? where account_id = ? and balance_currency = ? and accounts.balance_units - ? - "+activeHolds+" >= coalesce(floor_units, ?)",
+		amount.Units, accountId, amount.Currency, amount.Units, minimumBalance.Units)
 	if err := result.Error; err != nil {
 		return fmt.Errorf("failed to save new balance : %v", err)
 	}
 
 	rowsAffected := result.RowsAffected
 	if rowsAffected == 0 {
-		return fmt.Errorf("failed to save new balance : Balance must be greater than %0.f", minimumBalance)
+		funds, err := a.GetFunds(accountId, tx)
+		if err != nil {
+			return Reject(ReasonAccountNotFound, "failed to save new balance : account doesn't exist")
+		}
+		return Reject(ReasonInsufficientFunds, "failed to save new balance : available funds are %s", funds.Available)
+	}
+
+	return nil
+}
+
+// SaveNewBalanceWithCharge debits a charge of the bank, such as overdraft interest, which may
+// take the balance below the floor of the account.
+func (a *AccountModel) SaveNewBalanceWithCharge(amount money.Money, accountId string, txs ...*gorm.DB) error {
+	var tx = a.DB
+
+	if len(txs) > 0 {
+		tx = txs[0]
+	}
+
+	result := tx.Exec("update accounts set balance_units = accounts.balance_units - ? where account_id = ? and balance_currency = ?", amount.Units, accountId, amount.Currency)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to save new balance : %v", err)
+	}
+
+	if result.RowsAffected == 0 {
+		return Reject(ReasonAccountNotFound, "failed to save new balance : account doesn't exist or doesn't hold %s", amount.Currency)
 	}
 
 	return nil
 }
 
-func (a *AccountModel) SaveNewBalance(newAccountBalance float64, accountId string) error {
-	err := a.DB.Exec("update accounts set balance = ? where account_id = ?", newAccountBalance, accountId).Error
+// GetFunds returns the balance of an account and what it can spend.
+func (a *AccountModel) GetFunds(accountId string, txs ...*gorm.DB) (*Funds, error) {
+	var tx = a.DB
+
+	if len(txs) > 0 {
+		tx = txs[0]
+	}
+
+	var rows []struct {
+		Units      int64
+		Currency   string
+		FloorUnits *int64
+		HeldUnits  int64
+	}
+	err := tx.Raw("select balance_units as units, balance_currency as currency, floor_units, "+activeHolds+" as held_units from accounts where account_id = ?", accountId).
+		Scan(&rows).Error
 	if err != nil {
-		return fmt.Errorf("failed to set state's account : %v", err)
+		return nil, fmt.Errorf("failed to get balance's account : %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("account doesn't exist")
+	}
+	row := rows[0]
+
+	floor := minimumBalance.Units
+	if row.FloorUnits != nil {
+		floor = *row.FloorUnits
+	}
+
+	max := func(a, b int64) int64 {
+		if a > b {
+			return a
+		}
+		return b
+	}
+
+	funds := &Funds{
+		Balance:          money.New(row.Units, row.Currency),
+		Held:             money.New(row.HeldUnits, row.Currency),
+		AvailableBalance: money.New(row.Units-row.HeldUnits, row.Currency),
+		Floor:            money.New(floor, row.Currency),
+		Available:        money.New(max(row.Units-row.HeldUnits-floor, 0), row.Currency),
+		OverdraftLimit:   money.New(max(-floor, 0), row.Currency),
+		OverdraftUsed:    money.New(max(-row.Units, 0), row.Currency),
+	}
+	funds.OverdraftRemaining = money.New(max(funds.OverdraftLimit.Units-funds.OverdraftUsed.Units, 0), row.Currency)
+	return funds, nil
+}
+
+// SetAccountFloor sets the floor of an account in the currency of its balance, nil to use the
+// legacy minimum balance. A negative floor grants an overdraft.
+func (a *AccountModel) SetAccountFloor(accountId string, floorUnits *int64) error {
+	result := a.DB.Exec("update accounts set floor_units = ? where account_id = ?", floorUnits, accountId)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to set floor's account : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("account doesn't exist")
 	}
 	return nil
 }
 
-func (a *AccountModel) SetAccountState(accountId string, state int) error {
-	err := a.DB.Exec("update accounts set state = ? where account_id = ?", state, accountId).Error
+func (a *AccountModel) SaveNewBalance(newAccountBalance money.Money, accountId string) error {
+	err := a.DB.Exec("update accounts set balance_units = ?, balance_currency = ? where account_id = ?", newAccountBalance.Units, newAccountBalance.Currency, accountId).Error
 	if err != nil {
 		return fmt.Errorf("failed to set state's account : %v", err)
 	}
 	return nil
 }
 
-func (a *AccountModel) GetAccountState(accountId string) (int, error) {
-	var state int
+func (a *AccountModel) GetAccountState(accountId string) (AccountState, error) {
+	var state AccountState
 	err := a.DB.Raw("select state from accounts where account_id = ?", accountId).Scan(&state).Error
 	if err != nil {
 		return 0, fmt.Errorf("failed to get state's account : %v", err)
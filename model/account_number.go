@@ -0,0 +1,78 @@
+package model
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Account numbers are IBAN-style : a two letters prefix, two check digits then a 12 digits basic
+// account number, e.g. XB74 0123 4567 8901. The prefix XB is a code no country uses, so numbers
+// can't be taken for real IBANs.
+const (
+	accountNumberPrefix = "XB"
+	accountNumberDigits = 12
+)
+
+var ErrInvalidAccountNumber = errors.New("account number is invalid, check it for typos")
+
+// NewAccountNumber generates a random account number with its check digits.
+func NewAccountNumber() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(accountNumberDigits), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate account number : %v", err)
+	}
+	bban := fmt.Sprintf("%0*d", accountNumberDigits, n)
+
+	// the check digits make the number, its first four characters moved to the end, 1 modulo 97
+	remainder := mod97(bban + accountNumberPrefix + "00")
+	return fmt.Sprintf("%s%02d%s", accountNumberPrefix, 98-remainder, bban), nil
+}
+
+// NormalizeAccountNumber removes the spaces of number, as printed, and uppercases it.
+func NormalizeAccountNumber(number string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(number), ""))
+}
+
+// ValidateAccountNumber checks the format and the check digits of a normalized account number.
+func ValidateAccountNumber(number string) error {
+	if len(number) != 4+accountNumberDigits || !strings.HasPrefix(number, accountNumberPrefix) {
+		return ErrInvalidAccountNumber
+	}
+	for _, c := range number[2:] {
+		if c < '0' || c > '9' {
+			return ErrInvalidAccountNumber
+		}
+	}
+	if mod97(number[4:]+number[:4]) != 1 {
+		return ErrInvalidAccountNumber
+	}
+	return nil
+}
+
+// FormatAccountNumber groups the characters of number by four, the way it is printed.
+func FormatAccountNumber(number string) string {
+	var groups []string
+	for len(number) > 4 {
+		groups = append(groups, number[:4])
+		number = number[4:]
+	}
+	return strings.Join(append(groups, number), " ")
+}
+
+// mod97 returns s modulo 97, s being digits and uppercase letters, letters counting as the
+// numbers 10 (A) to 35 (Z).
+func mod97(s string) int {
+	remainder := 0
+	for _, c := range s {
+		if c >= 'A' && c <= 'Z' {
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(c-'0')) % 97
+		}
+	}
+	return remainder
+}
@@ -0,0 +1,60 @@
+package model
+
+import "testing"
+
+func TestNewAccountNumber(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		number, err := NewAccountNumber()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateAccountNumber(number); err != nil {
+			t.Fatalf("generated %s is invalid : %v", number, err)
+		}
+	}
+}
+
+func TestValidateAccountNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{"XB74012345678901", true},
+		{NormalizeAccountNumber("xb74 0123 4567 8901"), true},
+		{"XB75012345678901", false}, // wrong check digits
+		{"XB74012345678910", false}, // swapped digits
+		{"XB74012345678902", false}, // one digit off
+		{"GB27012345678901", false}, // another prefix
+		{"XB7401234567890", false},  // too short
+		{"XB740123456789012", false},
+		{"XB74O12345678901", false}, // a letter among the digits
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateAccountNumber(tt.number)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateAccountNumber(%q) = %v, want valid %v", tt.number, err, tt.valid)
+		}
+	}
+}
+
+func TestFormatAccountNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"XB74012345678901", "XB74 0123 4567 8901"},
+		{"XB7401", "XB74 01"},
+		{"XB74", "XB74"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatAccountNumber(tt.number); got != tt.want {
+			t.Errorf("FormatAccountNumber(%q) = %q, want %q", tt.number, got, tt.want)
+		}
+		if got := NormalizeAccountNumber(tt.want); got != tt.number {
+			t.Errorf("NormalizeAccountNumber(%q) = %q, want %q", tt.want, got, tt.number)
+		}
+	}
+}
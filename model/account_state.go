@@ -0,0 +1,246 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountState is stored as an integer in accounts.state. Active is 0 so that accounts created
+// before states were enforced, which all hold 0, stay usable.
+type AccountState int32
+
+const (
+	AccountActive AccountState = iota
+	AccountPendingVerification
+	AccountFrozen
+	AccountDebitBlocked
+	AccountClosed
+)
+
+var accountStateNames = map[AccountState]string{
+	AccountActive:              "ACTIVE",
+	AccountPendingVerification: "PENDING_VERIFICATION",
+	AccountFrozen:              "FROZEN",
+	AccountDebitBlocked:        "DEBIT_BLOCKED",
+	AccountClosed:              "CLOSED",
+}
+
+// accountStateTransitions lists the states each state may move to. Closed is final.
+var accountStateTransitions = map[AccountState][]AccountState{
+	AccountPendingVerification: {AccountActive, AccountClosed},
+	AccountActive:              {AccountFrozen, AccountDebitBlocked, AccountClosed},
+	AccountFrozen:              {AccountActive, AccountDebitBlocked, AccountClosed},
+	AccountDebitBlocked:        {AccountActive, AccountFrozen, AccountClosed},
+	AccountClosed:              {},
+}
+
+// Reasons for transactions rejected because of the state of an account.
+const (
+	ReasonAccountPendingVerification = "ACCOUNT_PENDING_VERIFICATION"
+	ReasonAccountFrozen              = "ACCOUNT_FROZEN"
+	ReasonAccountDebitBlocked        = "ACCOUNT_DEBIT_BLOCKED"
+	ReasonAccountClosed              = "ACCOUNT_CLOSED"
+)
+
+var accountStateReasons = map[AccountState]string{
+	AccountPendingVerification: ReasonAccountPendingVerification,
+	AccountFrozen:              ReasonAccountFrozen,
+	AccountDebitBlocked:        ReasonAccountDebitBlocked,
+	AccountClosed:              ReasonAccountClosed,
+}
+
+func (s AccountState) String() string {
+	if name, ok := accountStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int32(s))
+}
+
+func ParseAccountState(name string) (AccountState, error) {
+	for state, stateName := range accountStateNames {
+		if strings.EqualFold(stateName, name) {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown account state : %s", name)
+}
+
+func (s AccountState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *AccountState) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("account state must be a string : %v", err)
+	}
+	state, err := ParseAccountState(name)
+	if err != nil {
+		return err
+	}
+	*s = state
+	return nil
+}
+
+// CanDebit tells whether money may leave an account in this state.
+func (s AccountState) CanDebit() bool {
+	return s == AccountActive
+}
+
+// CanCredit tells whether money may enter an account in this state.
+func (s AccountState) CanCredit() bool {
+	return s == AccountActive || s == AccountDebitBlocked
+}
+
+func (s AccountState) CanTransitionTo(to AccountState) bool {
+	for _, allowed := range accountStateTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckMovement returns a RejectionError when the state forbids a debit (or a credit).
+func (s AccountState) CheckMovement(accountId string, debit bool) error {
+	if debit && s.CanDebit() || !debit && s.CanCredit() {
+		return nil
+	}
+
+	direction := "credited"
+	if debit {
+		direction = "debited"
+	}
+	return Reject(accountStateReasons[s], "account %s is %s and can't be %s", accountId, s, direction)
+}
+
+// AccountStateChange records who moved an account to another state, when and why.
+type AccountStateChange struct {
+	ChangeId    int64  `gorm:"primaryKey;autoIncrement"`
+	AccountId   string `gorm:"index"`
+	FromState   AccountState
+	ToState     AccountState
+	Reason      string
+	ActorId     string
+	CreatedTime time.Time
+}
+
+// ChangeAccountState moves an account to state to if the transition is allowed, and records the
+// change with its mandatory reason and the actor who made it.
+func (a *AccountModel) ChangeAccountState(accountId string, to AccountState, reason, actorId string) (*AccountStateChange, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("reason must not be blank")
+	}
+
+	var change *AccountStateChange
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		var current []AccountState
+		err := tx.Raw("select state from accounts where account_id = ? for update", accountId).Scan(&current).Error
+		if err != nil {
+			return fmt.Errorf("failed to get state's account : %v", err)
+		}
+		if len(current) == 0 {
+			return errors.New("account doesn't exist")
+		}
+
+		from := current[0]
+		if !from.CanTransitionTo(to) {
+			return fmt.Errorf("account can't go from %s to %s", from, to)
+		}
+
+		err = tx.Exec("update accounts set state = ? where account_id = ?", to, accountId).Error
+		if err != nil {
+			return fmt.Errorf("failed to set state's account : %v", err)
+		}
+
+		change = &AccountStateChange{
+			AccountId:   accountId,
+			FromState:   from,
+			ToState:     to,
+			Reason:      reason,
+			ActorId:     actorId,
+			CreatedTime: time.Now(),
+		}
+		err = tx.Create(change).Error
+		if err != nil {
+			return fmt.Errorf("failed to save state change : %v", err)
+		}
+
+		if to == AccountFrozen {
+			return emitWebhookEvent(tx, EventAccountFrozen, accountId, map[string]interface{}{
+				"account_id":   accountId,
+				"from_state":   from.String(),
+				"to_state":     to.String(),
+				"reason":       reason,
+				"created_time": change.CreatedTime,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// LockAccounts locks the rows of the customer accounts among accountIds in the order of their
+// ids, so that transactions moving money between the same accounts in opposite directions wait
+// for each other instead of deadlocking.
+func (a *AccountModel) LockAccounts(accountIds []string, dbTx *gorm.DB) error {
+	ids := make([]string, 0, len(accountIds))
+	for _, id := range accountIds {
+		if id != "" && !IsInternalAccount(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		err := dbTx.Exec("select 1 from accounts where account_id = ? for update", id).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock account : %v", err)
+		}
+	}
+	return nil
+}
+
+func (a *AccountModel) GetAccountStateHistory(accountId string) ([]AccountStateChange, error) {
+	var changes []AccountStateChange
+	err := a.DB.Where("account_id = ?", accountId).Order("created_time desc").Find(&changes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state changes : %v", err)
+	}
+	return changes, nil
+}
+
+// CheckAccountMovement verifies that accountId exists and that its state allows a debit (or a
+// credit). With a transaction the row is locked so the state can't change before commit ; the
+// lock is exclusive since the balance is updated next, and a shared one would deadlock two
+// concurrent postings to the account when both upgrade it.
+func (a *AccountModel) CheckAccountMovement(accountId string, debit bool, txs ...*gorm.DB) error {
+	var tx = a.DB
+	query := "select state from accounts where account_id = ?"
+
+	if len(txs) > 0 {
+		tx = txs[0]
+		query += " for update"
+	}
+
+	var states []AccountState
+	err := tx.Raw(query, accountId).Scan(&states).Error
+	if err != nil {
+		return fmt.Errorf("failed to get state's account : %v", err)
+	}
+	if len(states) == 0 {
+		return Reject(ReasonAccountNotFound, "account %s doesn't exist", accountId)
+	}
+
+	return states[0].CheckMovement(accountId, debit)
+}
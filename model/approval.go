@@ -0,0 +1,281 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TransactionAwaitingApproval is the state of a transaction held back until enough holders of
+// its account approve it ; it then becomes PENDING and is queued.
+const TransactionAwaitingApproval = "AWAITING_APPROVAL"
+
+// ReasonApprovalRejected rejects a transaction a holder of its account refused.
+const ReasonApprovalRejected = "APPROVAL_REJECTED"
+
+const (
+	ApprovalApproved = "APPROVED"
+	ApprovalRejected = "REJECTED"
+)
+
+// approvalTypes are the transaction types an approval policy may cover.
+var approvalTypes = []string{"Withdraw", "Transfer"}
+
+var ErrApprovalPolicyNotFound = errors.New("approval policy doesn't exist")
+
+// ApprovalPolicy requires Approvals holders of AccountId, the initiator included, to approve its
+// transactions of Type over Over.
+type ApprovalPolicy struct {
+	AccountId   string      `gorm:"primaryKey"`
+	Type        string      `gorm:"primaryKey"`
+	Over        money.Money `gorm:"embedded;embeddedPrefix:over_"`
+	Approvals   int
+	UpdatedBy   string
+	UpdatedTime time.Time
+}
+
+// Approval is the decision of one holder on a transaction awaiting approval.
+type Approval struct {
+	TransactionId string `gorm:"primaryKey"`
+	UserId        string `gorm:"primaryKey"`
+	Decision      string
+	Comment       string
+	CreatedTime   time.Time
+}
+
+// PendingApproval is a transaction awaiting approval with the decisions already made.
+type PendingApproval struct {
+	Transaction
+	Approved  int
+	Approvals []Approval
+}
+
+type ApprovalModel struct {
+	DB *gorm.DB
+}
+
+func NewApprovalModel(db *gorm.DB) *ApprovalModel {
+	return &ApprovalModel{
+		DB: db,
+	}
+}
+
+// SetPolicy creates or replaces the policy of policy.AccountId for policy.Type. It can't ask for
+// more approvals than the account has holders able to give them.
+func (m *ApprovalModel) SetPolicy(policy *ApprovalPolicy, accountModel *AccountModel) error {
+	valid := false
+	for _, t := range approvalTypes {
+		valid = valid || t == policy.Type
+	}
+	if !valid {
+		return fmt.Errorf("approval policies only cover %s", strings.Join(approvalTypes, ", "))
+	}
+	if policy.Approvals < 2 {
+		return errors.New("approvals must be at least 2")
+	}
+	if policy.Over.IsNegative() {
+		return errors.New("over must not be negative")
+	}
+
+	operators, err := accountModel.CountOperators(policy.AccountId)
+	if err != nil {
+		return err
+	}
+	if policy.Approvals > operators {
+		return fmt.Errorf("the account only has %d holders able to approve", operators)
+	}
+
+	policy.UpdatedTime = time.Now()
+	err = m.DB.Exec(`insert into approval_policies (account_id, type, over_units, over_currency, approvals, updated_by, updated_time)
+		values (?, ?, ?, ?, ?, ?, ?)
+		on conflict (account_id, type) do update set over_units = excluded.over_units, over_currency = excluded.over_currency,
+		approvals = excluded.approvals, updated_by = excluded.updated_by, updated_time = excluded.updated_time`,
+		policy.AccountId, policy.Type, policy.Over.Units, policy.Over.Currency, policy.Approvals, policy.UpdatedBy, policy.UpdatedTime).Error
+	if err != nil {
+		return fmt.Errorf("failed to save approval policy : %v", err)
+	}
+	return nil
+}
+
+func (m *ApprovalModel) DeletePolicy(accountId, txType string) error {
+	result := m.DB.Exec("delete from approval_policies where account_id = ? and type = ?", accountId, txType)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to delete approval policy : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return ErrApprovalPolicyNotFound
+	}
+	return nil
+}
+
+func (m *ApprovalModel) Policies(accountId string) ([]ApprovalPolicy, error) {
+	var policies []ApprovalPolicy
+	err := m.DB.Where("account_id = ?", accountId).Order("type").Find(&policies).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approval policies : %v", err)
+	}
+	return policies, nil
+}
+
+// RequiredApprovals returns the number of holders who must approve tx, 1 when its initiator is
+// enough. A tx in another currency than the policy of its account is refused : its amount can't
+// be compared with the threshold.
+func (m *ApprovalModel) RequiredApprovals(tx *Transaction) (int, error) {
+	var policies []ApprovalPolicy
+	err := m.DB.Where("account_id = ? and type = ?", tx.Sender, tx.Type).Find(&policies).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get approval policy : %v", err)
+	}
+	if len(policies) == 0 {
+		return 1, nil
+	}
+	if !policies[0].Over.SameCurrency(tx.Amount) {
+		return 0, fmt.Errorf("the approval policy of the account is in %s, not %s", policies[0].Over.Currency, tx.Amount.Currency)
+	}
+	if tx.Amount.Units <= policies[0].Over.Units {
+		return 1, nil
+	}
+	return policies[0].Approvals, nil
+}
+
+// SubmitForApproval saves tx AWAITING_APPROVAL of required holders, initiatorId having approved.
+// Without an initiator, e.g. for a standing order of an older version, every approval is needed.
+func (m *ApprovalModel) SubmitForApproval(tx *Transaction, initiatorId string, required int, transactionModel *TransactionModel) error {
+	return m.DB.Transaction(func(dbTx *gorm.DB) error {
+		tx.State = TransactionAwaitingApproval
+		tx.ApprovalsRequired = required
+		err := transactionModel.Save(tx, dbTx)
+		if err != nil {
+			return err
+		}
+		if initiatorId == "" {
+			return nil
+		}
+
+		err = dbTx.Create(&Approval{
+			TransactionId: tx.TransactionId,
+			UserId:        initiatorId,
+			Decision:      ApprovalApproved,
+			CreatedTime:   time.Now(),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to save approval : %v", err)
+		}
+		return nil
+	})
+}
+
+// Decide records the decision of userId, who must operate the account, on a transaction awaiting
+// approval. A rejection rejects the transaction ; the last approval needed makes it PENDING and
+// ready is then set for the caller to queue it.
+func (m *ApprovalModel) Decide(transactionId, userId, decision, comment string, accountModel *AccountModel) (tx *Transaction, ready bool, err error) {
+	if decision != ApprovalApproved && decision != ApprovalRejected {
+		return nil, false, fmt.Errorf("decision must be %s or %s", ApprovalApproved, ApprovalRejected)
+	}
+
+	err = m.DB.Transaction(func(dbTx *gorm.DB) error {
+		var current Transaction
+		result := dbTx.Raw("select * from transactions where transaction_id = ? for update", transactionId).Scan(&current)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to get transaction : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return ErrTransactionNotFound
+		}
+
+		role, err := accountModel.HolderRole(current.Sender, userId)
+		if err != nil {
+			return ErrTransactionNotFound
+		}
+		if !CanOperate(role) {
+			return errors.New("viewers can't approve transactions")
+		}
+		if current.State != TransactionAwaitingApproval {
+			return fmt.Errorf("transaction is %s, not awaiting approval", current.State)
+		}
+
+		result = dbTx.Exec(`insert into approvals (transaction_id, user_id, decision, comment, created_time) values (?, ?, ?, ?, ?)
+			on conflict do nothing`, transactionId, userId, decision, comment, time.Now())
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to save approval : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("you already decided on this transaction")
+		}
+
+		now := time.Now()
+		if decision == ApprovalRejected {
+			current.State = TransactionRejected
+			current.Reason = ReasonApprovalRejected
+			current.Detail = "rejected by a holder of the account"
+			current.RejectedTime = &now
+			err = dbTx.Exec("update transactions set state = ?, reason = ?, detail = ?, rejected_time = ? where transaction_id = ?",
+				current.State, current.Reason, current.Detail, now, transactionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to reject transaction : %v", err)
+			}
+			tx = &current
+			return emitTransactionRejected(dbTx, tx)
+		}
+
+		var approved int64
+		err = dbTx.Raw("select count(*) from approvals where transaction_id = ? and decision = ?", transactionId, ApprovalApproved).Scan(&approved).Error
+		if err != nil {
+			return fmt.Errorf("failed to count approvals : %v", err)
+		}
+		if int(approved) >= current.ApprovalsRequired {
+			current.State = TransactionPending
+			err = dbTx.Exec("update transactions set state = ? where transaction_id = ?", current.State, transactionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to approve transaction : %v", err)
+			}
+			ready = true
+		}
+		tx = &current
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return tx, ready, nil
+}
+
+// Pending returns the transactions awaiting approval on the accounts userId operates, oldest first.
+func (m *ApprovalModel) Pending(userId string) ([]PendingApproval, error) {
+	var transactions []Transaction
+	err := m.DB.Where("state = ? and sender in (select account_id from account_holders where user_id = ? and role in ?)",
+		TransactionAwaitingApproval, userId, []string{HolderOwner, HolderHolder}).
+		Order("created_time").Find(&transactions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions awaiting approval : %v", err)
+	}
+
+	pending := make([]PendingApproval, 0, len(transactions))
+	for _, tx := range transactions {
+		approvals, err := m.Approvals(tx.TransactionId)
+		if err != nil {
+			return nil, err
+		}
+		p := PendingApproval{Transaction: tx, Approvals: approvals}
+		for _, approval := range approvals {
+			if approval.Decision == ApprovalApproved {
+				p.Approved++
+			}
+		}
+		pending = append(pending, p)
+	}
+	return pending, nil
+}
+
+func (m *ApprovalModel) Approvals(transactionId string) ([]Approval, error) {
+	var approvals []Approval
+	err := m.DB.Where("transaction_id = ?", transactionId).Order("created_time").Find(&approvals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approvals : %v", err)
+	}
+	return approvals, nil
+}
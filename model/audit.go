@@ -0,0 +1,212 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Events of the audit log.
+const (
+	AuditRegistered          = "auth.registered"
+	AuditLoginSucceeded      = "auth.login.succeeded"
+	AuditLoginFailed         = "auth.login.failed"
+	AuditTokenIssued         = "auth.token.issued"
+	AuditRefreshTokenReused  = "auth.refresh_token.reused"
+	AuditLogout              = "auth.logout"
+	AuditTransactionRequest  = "transaction.requested"
+	AuditBalanceChanged      = "balance.changed"
+	AuditAccountState        = "admin.account.state"
+	AuditAccountTier         = "admin.account.tier"
+	AuditAccountFloor        = "admin.account.floor"
+	AuditAccountProduct      = "admin.account.product"
+	AuditAccountLimits       = "admin.account.limits"
+	AuditUserRole            = "admin.user.role"
+	AuditTransactionReversal = "admin.transaction.reversal"
+	AuditRiskCaseDecision    = "admin.risk_case.decision"
+)
+
+// auditLockKey is the advisory lock serializing appends, so that each entry chains to the last.
+const auditLockKey = 7291453001
+
+// AuditEntry is a line of the append-only audit log : Event done by ActorId (blank for the
+// system) on TargetId, from a request, with the values Before and After it (JSON). Hash covers
+// the entry and PrevHash, the Hash of the entry Seq-1, so editing or deleting an entry breaks the
+// chain, see AuditModel.Verify.
+type AuditEntry struct {
+	Seq        int64 `gorm:"primaryKey;autoIncrement:false"`
+	Time       time.Time
+	Event      string `gorm:"index"`
+	ActorId    string `gorm:"index"`
+	ActorRole  string
+	TargetType string
+	TargetId   string `gorm:"index"`
+	IP         string
+	UserAgent  string
+	RequestId  string `gorm:"index"`
+	Before     string
+	After      string
+	Detail     string
+	PrevHash   string
+	Hash       string
+}
+
+// computeHash returns the hash of every field of e but Hash.
+func (e *AuditEntry) computeHash() string {
+	content, _ := json.Marshal([]interface{}{
+		e.Seq, e.Time.UTC().Format(time.RFC3339Nano), e.Event, e.ActorId, e.ActorRole, e.TargetType, e.TargetId,
+		e.IP, e.UserAgent, e.RequestId, e.Before, e.After, e.Detail, e.PrevHash,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditValue encodes a before or after value of an entry.
+func AuditValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+type AuditModel struct {
+	DB *gorm.DB
+}
+
+func NewAuditModel(db *gorm.DB) *AuditModel {
+	return &AuditModel{
+		DB: db,
+	}
+}
+
+// Append chains entry to the log. Given a transaction, the entry is only kept if it commits, and
+// other appends wait for it.
+func (m *AuditModel) Append(entry *AuditEntry, txs ...*gorm.DB) error {
+	if len(txs) > 0 {
+		return appendAudit(txs[0], entry)
+	}
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		return appendAudit(tx, entry)
+	})
+}
+
+func appendAudit(tx *gorm.DB, entry *AuditEntry) error {
+	err := tx.Exec("select pg_advisory_xact_lock(?)", auditLockKey).Error
+	if err != nil {
+		return fmt.Errorf("failed to lock audit log : %v", err)
+	}
+
+	var last []AuditEntry
+	err = tx.Raw("select seq, hash from audit_entries order by seq desc limit 1").Scan(&last).Error
+	if err != nil {
+		return fmt.Errorf("failed to get last audit entry : %v", err)
+	}
+
+	entry.Seq = 1
+	entry.PrevHash = ""
+	if len(last) > 0 {
+		entry.Seq = last[0].Seq + 1
+		entry.PrevHash = last[0].Hash
+	}
+	// postgres keeps microseconds : the hash must cover the time read back
+	entry.Time = time.Now().UTC().Truncate(time.Microsecond)
+	entry.Hash = entry.computeHash()
+
+	err = tx.Create(entry).Error
+	if err != nil {
+		return fmt.Errorf("failed to save audit entry : %v", err)
+	}
+	return nil
+}
+
+// AuditFilter selects entries of the log ; blank fields don't filter. BeforeSeq pages backwards.
+type AuditFilter struct {
+	Event     string
+	ActorId   string
+	TargetId  string
+	RequestId string
+	BeforeSeq int64
+	Limit     int
+}
+
+// List returns the entries matching filter, newest first.
+func (m *AuditModel) List(filter AuditFilter) ([]AuditEntry, error) {
+	query := m.DB.Model(&AuditEntry{})
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if filter.ActorId != "" {
+		query = query.Where("actor_id = ?", filter.ActorId)
+	}
+	if filter.TargetId != "" {
+		query = query.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.RequestId != "" {
+		query = query.Where("request_id = ?", filter.RequestId)
+	}
+	if filter.BeforeSeq > 0 {
+		query = query.Where("seq < ?", filter.BeforeSeq)
+	}
+
+	var entries []AuditEntry
+	err := query.Order("seq desc").Limit(filter.Limit).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit entries : %v", err)
+	}
+	return entries, nil
+}
+
+// HasHash tells whether an entry of the log has hash.
+func (m *AuditModel) HasHash(hash string) (bool, error) {
+	var count int64
+	err := m.DB.Raw("select count(*) from audit_entries where hash = ?", hash).Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get audit entry : %v", err)
+	}
+	return count > 0, nil
+}
+
+// Verify walks the whole log and returns a description of every broken link : a missing
+// sequence number (deleted entries), an entry whose hash doesn't match its content (edited) or
+// whose PrevHash isn't the hash of the entry before. It also returns the last entry, whose hash
+// should be kept : entries deleted from the end of the log can only be detected against it.
+func (m *AuditModel) Verify() (problems []string, last *AuditEntry, err error) {
+	const batch = 1000
+
+	var seq int64
+	prevHash := ""
+	for {
+		var entries []AuditEntry
+		err := m.DB.Where("seq > ?", seq).Order("seq").Limit(batch).Find(&entries).Error
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get audit entries : %v", err)
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Seq != seq+1 {
+				problems = append(problems, fmt.Sprintf("entries %d to %d are missing", seq+1, entry.Seq-1))
+			} else if entry.PrevHash != prevHash {
+				problems = append(problems, fmt.Sprintf("entry %d doesn't follow entry %d : the previous entry was changed", entry.Seq, seq))
+			}
+			if entry.computeHash() != entry.Hash {
+				problems = append(problems, fmt.Sprintf("entry %d was modified : its hash doesn't match its content", entry.Seq))
+			}
+			seq = entry.Seq
+			prevHash = entry.Hash
+			last = entry
+		}
+
+		if len(entries) < batch {
+			return problems, last, nil
+		}
+	}
+}
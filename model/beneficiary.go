@@ -0,0 +1,141 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrBeneficiaryNotFound = errors.New("beneficiary doesn't exist")
+
+// Beneficiary is a payee saved by UserId : the account numbered AccountNumber, of the person the
+// user calls Name. MatchResult is the result of the last confirmation of payee, see Verify.
+type Beneficiary struct {
+	BeneficiaryId string `gorm:"primaryKey"`
+	UserId        string `gorm:"uniqueIndex:idx_beneficiaries_user_account_number"`
+	AccountNumber string `gorm:"uniqueIndex:idx_beneficiaries_user_account_number"`
+	Name          string
+	Nickname      string
+	MatchResult   string
+	// MatchedName is the name of the account on a close match.
+	MatchedName  string
+	VerifiedTime time.Time
+	CreatedTime  time.Time
+}
+
+type BeneficiaryModel struct {
+	DB *gorm.DB
+}
+
+func NewBeneficiaryModel(db *gorm.DB) *BeneficiaryModel {
+	return &BeneficiaryModel{
+		DB: db,
+	}
+}
+
+// Save confirms the payee of beneficiary, then saves it whatever the result ; transfers to a
+// beneficiary whose name doesn't match must be confirmed by the payer.
+func (b *BeneficiaryModel) Save(beneficiary *Beneficiary, accountModel *AccountModel) error {
+	check, err := accountModel.ConfirmPayee(beneficiary.AccountNumber, beneficiary.Name)
+	if err != nil {
+		return err
+	}
+
+	var exists int64
+	err = b.DB.Raw("select count(*) from beneficiaries where user_id = ? and account_number = ?", beneficiary.UserId, check.AccountNumber).Scan(&exists).Error
+	if err != nil {
+		return fmt.Errorf("failed to get beneficiary : %v", err)
+	}
+	if exists > 0 {
+		return errors.New("beneficiary is already saved")
+	}
+
+	beneficiary.BeneficiaryId = uuid.NewString()
+	beneficiary.AccountNumber = check.AccountNumber
+	beneficiary.Name = strings.TrimSpace(beneficiary.Name)
+	beneficiary.MatchResult = check.Result
+	beneficiary.MatchedName = check.Name
+	beneficiary.CreatedTime = time.Now()
+	beneficiary.VerifiedTime = beneficiary.CreatedTime
+
+	err = b.DB.Create(beneficiary).Error
+	if err != nil {
+		return fmt.Errorf("failed to save beneficiary : %v", err)
+	}
+	return nil
+}
+
+// Get returns a beneficiary of userId.
+func (b *BeneficiaryModel) Get(beneficiaryId, userId string) (*Beneficiary, error) {
+	var beneficiary Beneficiary
+	result := b.DB.Where("beneficiary_id = ? and user_id = ?", beneficiaryId, userId).Limit(1).Find(&beneficiary)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get beneficiary : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrBeneficiaryNotFound
+	}
+	return &beneficiary, nil
+}
+
+func (b *BeneficiaryModel) ListByUser(userId string) ([]Beneficiary, error) {
+	var beneficiaries []Beneficiary
+	err := b.DB.Where("user_id = ?", userId).Order("nickname, name").Find(&beneficiaries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get beneficiaries : %v", err)
+	}
+	return beneficiaries, nil
+}
+
+func (b *BeneficiaryModel) Rename(beneficiaryId, userId, nickname string) error {
+	result := b.DB.Exec("update beneficiaries set nickname = ? where beneficiary_id = ? and user_id = ?", nickname, beneficiaryId, userId)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to rename beneficiary : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return ErrBeneficiaryNotFound
+	}
+	return nil
+}
+
+// Verify confirms the payee of a beneficiary of userId again, name replacing its name when given,
+// and saves the result.
+func (b *BeneficiaryModel) Verify(beneficiaryId, userId, name string, accountModel *AccountModel) (*Beneficiary, error) {
+	beneficiary, err := b.Get(beneficiaryId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(name) != "" {
+		beneficiary.Name = strings.TrimSpace(name)
+	}
+
+	check, err := accountModel.ConfirmPayee(beneficiary.AccountNumber, beneficiary.Name)
+	if err != nil {
+		return nil, err
+	}
+	beneficiary.MatchResult = check.Result
+	beneficiary.MatchedName = check.Name
+	beneficiary.VerifiedTime = time.Now()
+
+	err = b.DB.Exec("update beneficiaries set name = ?, match_result = ?, matched_name = ?, verified_time = ? where beneficiary_id = ?",
+		beneficiary.Name, beneficiary.MatchResult, beneficiary.MatchedName, beneficiary.VerifiedTime, beneficiaryId).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save beneficiary : %v", err)
+	}
+	return beneficiary, nil
+}
+
+func (b *BeneficiaryModel) Delete(beneficiaryId, userId string) error {
+	result := b.DB.Exec("delete from beneficiaries where beneficiary_id = ? and user_id = ?", beneficiaryId, userId)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to delete beneficiary : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return ErrBeneficiaryNotFound
+	}
+	return nil
+}
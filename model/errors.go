@@ -0,0 +1,75 @@
+package model
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Machine-readable reasons stored on rejected transactions.
+const (
+	ReasonInsufficientFunds  = "INSUFFICIENT_FUNDS"
+	ReasonAccountNotFound    = "ACCOUNT_NOT_FOUND"
+	ReasonCurrencyMismatch   = "CURRENCY_MISMATCH"
+	ReasonInvalidTransaction = "INVALID_TRANSACTION"
+	ReasonQueueUnavailable   = "QUEUE_UNAVAILABLE"
+	ReasonProcessingError    = "PROCESSING_ERROR"
+	ReasonLimitExceeded      = "LIMIT_EXCEEDED"
+	ReasonRiskBlocked        = "RISK_BLOCKED"
+	ReasonRiskDeclined       = "RISK_DECLINED"
+)
+
+// RejectionError is returned when a transaction breaks a business rule. Unlike other errors it
+// is final : the transaction is marked REJECTED with Reason instead of FAILED.
+type RejectionError struct {
+	Reason  string
+	Message string
+}
+
+func (e *RejectionError) Error() string {
+	return e.Message
+}
+
+func Reject(reason string, format string, args ...interface{}) error {
+	return &RejectionError{
+		Reason:  reason,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// sqlState finds the SQLSTATE code postgres errors carry in their message, which survives the
+// "failed to ... : %v" wrapping of the models.
+var sqlState = regexp.MustCompile(`\(SQLSTATE ([0-9A-Z]{5})\)`)
+
+// IsTransientError tells whether err may not happen again on a retry : a deadlock, a
+// serialization failure, a lost or refused connection, a server shutting down or out of
+// resources. Other errors are deterministic.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	message := err.Error()
+	if match := sqlState.FindStringSubmatch(message); match != nil {
+		code := match[1]
+		return code == "40001" || code == "40P01" || strings.HasPrefix(code, "08") ||
+			strings.HasPrefix(code, "53") || strings.HasPrefix(code, "57P")
+	}
+	for _, lost := range []string{"connection refused", "connection reset", "broken pipe", "conn closed", "unexpected EOF", "bad connection"} {
+		if strings.Contains(message, lost) {
+			return true
+		}
+	}
+	return false
+}
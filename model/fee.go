@@ -0,0 +1,108 @@
+package model
+
+import (
+	"account-management/fee"
+	"account-management/money"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// feeSchedule holds the fee rules loaded from the configuration.
+var feeSchedule fee.Schedule
+
+func SetFeeSchedule(schedule fee.Schedule) {
+	feeSchedule = schedule
+}
+
+// FeeQuote is the fee a transaction would be charged now.
+type FeeQuote struct {
+	Type   string
+	Amount money.Money
+	Fee    money.Money
+	Total  money.Money
+	// FreeRemaining is the number of transactions of this type still free this month, nil when
+	// the rule has no free allowance.
+	FreeRemaining *int
+}
+
+type FeeModel struct {
+	DB *gorm.DB
+}
+
+func NewFeeModel(db *gorm.DB) *FeeModel {
+	return &FeeModel{
+		DB: db,
+	}
+}
+
+// Quote prices a transaction of txType and amount sent by accountId. The worker calls it with
+// the transaction that posts it, after the sender row was locked by the limits check.
+func (f *FeeModel) Quote(accountId, txType string, amount money.Money, txs ...*gorm.DB) (*FeeQuote, error) {
+	var db = f.DB
+
+	if len(txs) > 0 {
+		db = txs[0]
+	}
+
+	quote := &FeeQuote{
+		Type:   txType,
+		Amount: amount,
+		Fee:    money.New(0, amount.Currency),
+		Total:  amount,
+	}
+
+	var tiers []string
+	err := db.Raw("select tier from accounts where account_id = ?", accountId).Scan(&tiers).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tier's account : %v", err)
+	}
+	if len(tiers) == 0 {
+		return nil, errors.New("account doesn't exist")
+	}
+
+	rule := feeSchedule.Find(txType, tiers[0])
+	if rule == nil {
+		return quote, nil
+	}
+
+	var used int64
+	if rule.FreePerMonth > 0 {
+		now := time.Now().UTC()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		err := db.Raw("select count(*) from transactions where sender = ? and type = ? and state = ? and completed_time >= ?",
+			accountId, txType, TransactionCompleted, month).Scan(&used).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count transactions : %v", err)
+		}
+
+		free := rule.FreePerMonth - int(used)
+		if free < 0 {
+			free = 0
+		}
+		quote.FreeRemaining = &free
+	}
+
+	quote.Fee, err = rule.Compute(amount, int(used))
+	if err != nil {
+		return nil, err
+	}
+	quote.Total, err = amount.Add(quote.Fee)
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// FeePostings returns the postings charging the fee of tx to its sender, none without a fee.
+func FeePostings(tx *Transaction) []Posting {
+	if !tx.Fee.IsPositive() {
+		return nil
+	}
+	return []Posting{
+		{AccountId: tx.Sender, Amount: tx.Fee.Neg()},
+		{AccountId: FeeIncomeAccountId, Amount: tx.Fee},
+	}
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"account-management/money"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HistoryFilter selects the transactions of an account returned by TransactionModel.History.
+// Zero values mean "no filter".
+type HistoryFilter struct {
+	Types     []string
+	States    []string
+	From      time.Time
+	To        time.Time
+	MinAmount *int64
+	MaxAmount *int64
+	Ascending bool
+	Limit     int
+	Cursor    string
+}
+
+// HistoryEntry is a transaction seen from one account : the direction of the money and the
+// account balance right after the transaction was posted (nil when it wasn't posted). Reversed
+// is the amount its completed reversals moved back, nil when it has none ; a reversal links
+// back to its transaction through ReversalOf.
+type HistoryEntry struct {
+	Transaction
+	Direction    string
+	BalanceAfter *money.Money
+	Reversed     *money.Money
+}
+
+type historyRow struct {
+	Transaction
+	BalanceAfter  *int64
+	ReversedUnits int64
+}
+
+func encodeHistoryCursor(tx *Transaction) string {
+	raw := tx.CreatedTime.UTC().Format(time.RFC3339Nano) + "|" + tx.TransactionId
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errors.New("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", errors.New("invalid cursor")
+	}
+	createdTime, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", errors.New("invalid cursor")
+	}
+	return createdTime, parts[1], nil
+}
+
+// History returns one page of the deposits, withdrawals and transfers (sent and received) of
+// accountId, ordered by creation time, and the cursor of the next page ("" on the last page).
+func (t *TransactionModel) History(accountId string, filter HistoryFilter) ([]HistoryEntry, string, error) {
+	// a transaction may have several entries (e.g. its fee), the balance after it is the one of
+	// its last posting on the account
+	query := t.DB.Table("transactions t").
+		Select(`t.*, (select p.balance_after from journal_entries j join postings p on p.entry_id = j.entry_id
+			where j.transaction_id = t.transaction_id and p.account_id = ? order by p.posting_id desc limit 1) as balance_after,
+			(select coalesce(sum(r.amount_units), 0) from transactions r where r.reversal_of = t.transaction_id and r.state = ?) as reversed_units`,
+			accountId, TransactionCompleted).
+		Where("(t.sender = ? or t.receiver = ?)", accountId, accountId)
+
+	if len(filter.Types) > 0 {
+		query = query.Where("t.type in ?", filter.Types)
+	}
+	if len(filter.States) > 0 {
+		query = query.Where("t.state in ?", filter.States)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("t.created_time >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("t.created_time < ?", filter.To)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("t.amount_units >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("t.amount_units <= ?", *filter.MaxAmount)
+	}
+
+	order := "desc"
+	comparison := "<"
+	if filter.Ascending {
+		order = "asc"
+		comparison = ">"
+	}
+
+	if filter.Cursor != "" {
+		createdTime, transactionId, err := decodeHistoryCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where(fmt.Sprintf("(t.created_time, t.transaction_id) %s (?, ?)", comparison), createdTime, transactionId)
+	}
+
+	var rows []historyRow
+	err := query.Order(fmt.Sprintf("t.created_time %s, t.transaction_id %s", order, order)).
+		Limit(filter.Limit + 1).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get transaction history : %v", err)
+	}
+
+	var nextCursor string
+	if len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+		nextCursor = encodeHistoryCursor(&rows[len(rows)-1].Transaction)
+	}
+
+	entries := make([]HistoryEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := HistoryEntry{
+			Transaction: row.Transaction,
+			Direction:   "CREDIT",
+		}
+		if row.Sender == accountId && !CreditsSender(row.Type) {
+			entry.Direction = "DEBIT"
+		}
+		if row.BalanceAfter != nil {
+			balance := money.New(*row.BalanceAfter, row.Amount.Currency)
+			entry.BalanceAfter = &balance
+		}
+		if row.ReversedUnits > 0 {
+			reversed := money.New(row.ReversedUnits, row.Amount.Currency)
+			entry.Reversed = &reversed
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nextCursor, nil
+}
@@ -0,0 +1,246 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+	HoldExpired  = "EXPIRED"
+)
+
+var ErrHoldNotFound = errors.New("hold doesn't exist")
+
+// activeHolds sums the funds reserved on the account of the surrounding query.
+const activeHolds = `(select coalesce(sum(h.amount_units), 0) from holds h
+	where h.account_id = accounts.account_id and h.state = '` + HoldActive + `' and h.expires_time > now())`
+
+// Hold reserves Amount on AccountId until it is captured into a transaction (a transfer to
+// Receiver, or a withdrawal without receiver), released or expired. A capture request sets
+// TransactionId ; the hold stays ACTIVE, still reserving the funds, until the worker posts it.
+type Hold struct {
+	HoldId         string `gorm:"primaryKey"`
+	AccountId      string `gorm:"index"`
+	Receiver       string
+	Amount         money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	CapturedAmount money.Money `gorm:"embedded;embeddedPrefix:captured_"`
+	Description    string
+	State          string `gorm:"index"`
+	TransactionId  string
+	ExpiresTime    time.Time
+	CreatedTime    time.Time
+	ClosedTime     *time.Time
+}
+
+type HoldModel struct {
+	DB *gorm.DB
+}
+
+func NewHoldModel(db *gorm.DB) *HoldModel {
+	return &HoldModel{
+		DB: db,
+	}
+}
+
+// Place reserves the amount of hold if the account has the funds for it.
+func (h *HoldModel) Place(hold *Hold, accountModel *AccountModel) error {
+	if !hold.Amount.IsPositive() {
+		return errors.New("amount must be greater than 0")
+	}
+	if !hold.ExpiresTime.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		// serializes with the debits of the account, which update its row
+		err := tx.Exec("select 1 from accounts where account_id = ? for update", hold.AccountId).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock account : %v", err)
+		}
+
+		err = accountModel.CheckAccountMovement(hold.AccountId, true, tx)
+		if err != nil {
+			return err
+		}
+
+		funds, err := accountModel.GetFunds(hold.AccountId, tx)
+		if err != nil {
+			return err
+		}
+		if !funds.Available.SameCurrency(hold.Amount) {
+			return Reject(ReasonCurrencyMismatch, "account holds %s, not %s", funds.Available.Currency, hold.Amount.Currency)
+		}
+		if hold.Amount.Units > funds.Available.Units {
+			return Reject(ReasonInsufficientFunds, "available funds are %s", funds.Available)
+		}
+
+		hold.HoldId = uuid.NewString()
+		hold.State = HoldActive
+		hold.CapturedAmount = money.New(0, hold.Amount.Currency)
+		hold.CreatedTime = time.Now()
+
+		err = tx.Create(hold).Error
+		if err != nil {
+			return fmt.Errorf("failed to save hold : %v", err)
+		}
+		return nil
+	})
+}
+
+func (h *HoldModel) Get(holdId string) (*Hold, error) {
+	var hold Hold
+	result := h.DB.Where("hold_id = ?", holdId).Limit(1).Find(&hold)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get hold : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrHoldNotFound
+	}
+	return &hold, nil
+}
+
+func (h *HoldModel) ListByAccount(accountId string) ([]Hold, error) {
+	var holds []Hold
+	err := h.DB.Where("account_id = ?", accountId).Order("created_time desc").Find(&holds).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get holds : %v", err)
+	}
+	return holds, nil
+}
+
+// captureInProgress tells whether the capture transaction of an active hold may still complete.
+func (h *HoldModel) captureInProgress(db *gorm.DB, hold *Hold) (bool, error) {
+	if hold.TransactionId == "" {
+		return false, nil
+	}
+	var states []string
+	err := db.Raw("select state from transactions where transaction_id = ?", hold.TransactionId).Scan(&states).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get capture transaction : %v", err)
+	}
+	return len(states) > 0 && (states[0] == TransactionPending || states[0] == TransactionUnderReview || states[0] == TransactionCompleted), nil
+}
+
+// lockActive locks an active, unexpired hold of accountId with no capture in progress.
+func (h *HoldModel) lockActive(db *gorm.DB, holdId, accountId string) (*Hold, error) {
+	var hold Hold
+	result := db.Raw("select * from holds where hold_id = ? and account_id = ? for update", holdId, accountId).Scan(&hold)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get hold : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrHoldNotFound
+	}
+	if hold.State != HoldActive || !hold.ExpiresTime.After(time.Now()) {
+		return nil, fmt.Errorf("hold is %s", strings.ToLower(holdState(&hold)))
+	}
+
+	inProgress, err := h.captureInProgress(db, &hold)
+	if err != nil {
+		return nil, err
+	}
+	if inProgress {
+		return nil, errors.New("hold is already being captured")
+	}
+	return &hold, nil
+}
+
+func holdState(hold *Hold) string {
+	if hold.State == HoldActive && !hold.ExpiresTime.After(time.Now()) {
+		return HoldExpired
+	}
+	return hold.State
+}
+
+// RequestCapture links tx, the capture of a hold of accountId, to the hold and saves tx as
+// PENDING, once. submit hands tx to the task queue after the hold is updated.
+func (h *HoldModel) RequestCapture(holdId, accountId string, tx *Transaction, transactionModel *TransactionModel) (*Hold, error) {
+	var hold *Hold
+	err := h.DB.Transaction(func(dbTx *gorm.DB) error {
+		var err error
+		hold, err = h.lockActive(dbTx, holdId, accountId)
+		if err != nil {
+			return err
+		}
+
+		if !tx.Amount.SameCurrency(hold.Amount) || tx.Amount.Units > hold.Amount.Units {
+			return fmt.Errorf("capture can't exceed the hold of %s", hold.Amount)
+		}
+
+		tx.HoldId = hold.HoldId
+		err = transactionModel.SavePending(tx, dbTx)
+		if err != nil {
+			return err
+		}
+
+		err = dbTx.Exec("update holds set transaction_id = ? where hold_id = ?", tx.TransactionId, hold.HoldId).Error
+		if err != nil {
+			return fmt.Errorf("failed to capture hold : %v", err)
+		}
+		hold.TransactionId = tx.TransactionId
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// Capture closes the hold captured by tx ; it runs in the worker's transaction before tx is
+// posted, so that the hold stops reserving the funds the posting takes.
+func (h *HoldModel) Capture(tx *Transaction, dbTx *gorm.DB) error {
+	var hold Hold
+	result := dbTx.Raw("select * from holds where hold_id = ? for update", tx.HoldId).Scan(&hold)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to get hold : %v", err)
+	}
+	if result.RowsAffected == 0 || hold.TransactionId != tx.TransactionId || hold.State != HoldActive {
+		return Reject(ReasonInvalidTransaction, "hold %s can't be captured by this transaction", tx.HoldId)
+	}
+
+	now := time.Now()
+	err := dbTx.Exec("update holds set state = ?, captured_units = ?, captured_currency = ?, closed_time = ? where hold_id = ?",
+		HoldCaptured, tx.Amount.Units, tx.Amount.Currency, now, hold.HoldId).Error
+	if err != nil {
+		return fmt.Errorf("failed to capture hold : %v", err)
+	}
+	return nil
+}
+
+// Release gives back the funds of an active hold of accountId.
+func (h *HoldModel) Release(holdId, accountId string) error {
+	return h.DB.Transaction(func(dbTx *gorm.DB) error {
+		hold, err := h.lockActive(dbTx, holdId, accountId)
+		if err != nil {
+			return err
+		}
+
+		err = dbTx.Exec("update holds set state = ?, closed_time = ? where hold_id = ?", HoldReleased, time.Now(), hold.HoldId).Error
+		if err != nil {
+			return fmt.Errorf("failed to release hold : %v", err)
+		}
+		return nil
+	})
+}
+
+// Expire marks EXPIRED the active holds past their expiry without a capture in progress, pending
+// or under review. Such holds already stopped reserving funds, this only records it.
+func (h *HoldModel) Expire(now time.Time) (int64, error) {
+	result := h.DB.Exec(`update holds set state = ?, closed_time = expires_time
+		where state = ? and expires_time <= ?
+		and not exists (select 1 from transactions t where t.transaction_id = holds.transaction_id and t.state in ?)`,
+		HoldExpired, HoldActive, now, []string{TransactionPending, TransactionUnderReview})
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to expire holds : %v", err)
+	}
+	return result.RowsAffected, nil
+}
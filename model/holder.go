@@ -0,0 +1,218 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles of the holders of an account. Owners and holders operate the account and approve its
+// transactions, only owners manage its holders and approval policies ; viewers only read it.
+const (
+	HolderOwner  = "OWNER"
+	HolderHolder = "HOLDER"
+	HolderViewer = "VIEWER"
+)
+
+var ErrNotHolder = errors.New("account doesn't exist")
+
+func IsValidHolderRole(role string) bool {
+	return role == HolderOwner || role == HolderHolder || role == HolderViewer
+}
+
+// CanOperate tells whether a holder of role may move money and approve transactions.
+func CanOperate(role string) bool {
+	return role == HolderOwner || role == HolderHolder
+}
+
+// AccountHolder gives UserId access to AccountId ; a joint account has several holders.
+type AccountHolder struct {
+	AccountId   string `gorm:"primaryKey"`
+	UserId      string `gorm:"primaryKey;index"`
+	Role        string
+	CreatedTime time.Time
+}
+
+// AccountHolderView is a holder with its username.
+type AccountHolderView struct {
+	AccountHolder
+	Username string
+}
+
+// HolderRole returns the role of userId on accountId, ErrNotHolder when it has none.
+func (a *AccountModel) HolderRole(accountId, userId string) (string, error) {
+	var roles []string
+	err := a.DB.Raw("select role from account_holders where account_id = ? and user_id = ?", accountId, userId).Scan(&roles).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get account holder : %v", err)
+	}
+	if len(roles) == 0 {
+		return "", ErrNotHolder
+	}
+	return roles[0], nil
+}
+
+// ListByUser returns the accounts userId holds, oldest first.
+func (a *AccountModel) ListByUser(userId string) ([]Account, error) {
+	var accounts []Account
+	err := a.DB.Where("account_id in (select account_id from account_holders where user_id = ?)", userId).
+		Order("created_time").Find(&accounts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts : %v", err)
+	}
+	return accounts, nil
+}
+
+// HasOpenAccount tells whether userId holds an account that isn't closed.
+func (a *AccountModel) HasOpenAccount(userId string) (bool, error) {
+	var count int64
+	err := a.DB.Raw("select count(*) from accounts a join account_holders h on h.account_id = a.account_id where h.user_id = ? and a.state <> ?",
+		userId, AccountClosed).Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get accounts : %v", err)
+	}
+	return count > 0, nil
+}
+
+func (a *AccountModel) ListHolders(accountId string) ([]AccountHolderView, error) {
+	var holders []AccountHolderView
+	err := a.DB.Raw(`select h.*, u.username from account_holders h join users u on u.user_id = h.user_id
+		where h.account_id = ? order by h.created_time`, accountId).Scan(&holders).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account holders : %v", err)
+	}
+	return holders, nil
+}
+
+// CountOperators returns the number of holders who may approve the transactions of accountId.
+func (a *AccountModel) CountOperators(accountId string, txs ...*gorm.DB) (int, error) {
+	var db = a.DB
+
+	if len(txs) > 0 {
+		db = txs[0]
+	}
+
+	var count int64
+	err := db.Raw("select count(*) from account_holders where account_id = ? and role in ?", accountId, []string{HolderOwner, HolderHolder}).
+		Scan(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count account holders : %v", err)
+	}
+	return int(count), nil
+}
+
+// SetHolder adds userId to the holders of accountId, or changes its role. An account always
+// keeps an owner, and enough holders able to approve for its approval policies.
+func (a *AccountModel) SetHolder(accountId, userId, role string) error {
+	if !IsValidHolderRole(role) {
+		return fmt.Errorf("unknown holder role : %s", role)
+	}
+
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("select 1 from accounts where account_id = ? for update", accountId).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock account : %v", err)
+		}
+
+		err = tx.Exec(`insert into account_holders (account_id, user_id, role, created_time) values (?, ?, ?, ?)
+			on conflict (account_id, user_id) do update set role = excluded.role`, accountId, userId, role, time.Now()).Error
+		if err != nil {
+			return fmt.Errorf("failed to save account holder : %v", err)
+		}
+		if !CanOperate(role) {
+			err = dropApprovals(tx, accountId, userId)
+			if err != nil {
+				return err
+			}
+		}
+		err = checkHasOwner(tx, accountId)
+		if err != nil {
+			return err
+		}
+		return a.checkEnoughOperators(tx, accountId)
+	})
+}
+
+// RemoveHolder takes the access of userId to accountId away, unless it is the last owner or one
+// of the holders its approval policies need.
+func (a *AccountModel) RemoveHolder(accountId, userId string) error {
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("select 1 from accounts where account_id = ? for update", accountId).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock account : %v", err)
+		}
+
+		result := tx.Exec("delete from account_holders where account_id = ? and user_id = ?", accountId, userId)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to remove account holder : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("user doesn't hold this account")
+		}
+
+		// the default account of the user must remain one it holds
+		err = tx.Exec(`update users set default_account_id = coalesce((select h.account_id from account_holders h
+			where h.user_id = users.user_id and h.role <> ? order by h.created_time limit 1), '')
+			where user_id = ? and default_account_id = ?`, HolderViewer, userId, accountId).Error
+		if err != nil {
+			return fmt.Errorf("failed to reset default account : %v", err)
+		}
+		err = dropApprovals(tx, accountId, userId)
+		if err != nil {
+			return err
+		}
+		err = checkHasOwner(tx, accountId)
+		if err != nil {
+			return err
+		}
+		return a.checkEnoughOperators(tx, accountId)
+	})
+}
+
+// checkEnoughOperators refuses to leave accountId with fewer holders able to approve than its
+// approval policies, or its transactions awaiting approval, require : they could never be
+// approved.
+func (a *AccountModel) checkEnoughOperators(tx *gorm.DB, accountId string) error {
+	var required int64
+	err := tx.Raw(`select greatest(
+		coalesce((select max(approvals) from approval_policies where account_id = ?), 0),
+		coalesce((select max(approvals_required) from transactions where sender = ? and state = ?), 0))`,
+		accountId, accountId, TransactionAwaitingApproval).Scan(&required).Error
+	if err != nil {
+		return fmt.Errorf("failed to get approval policies : %v", err)
+	}
+
+	operators, err := a.CountOperators(accountId, tx)
+	if err != nil {
+		return err
+	}
+	if int64(operators) < required {
+		return fmt.Errorf("the account needs %d holders able to approve, change its approval policies first", required)
+	}
+	return nil
+}
+
+// dropApprovals forgets the approvals userId gave to the transactions of accountId still awaiting
+// approval, once it can no longer operate the account.
+func dropApprovals(tx *gorm.DB, accountId, userId string) error {
+	err := tx.Exec(`delete from approvals where user_id = ? and transaction_id in
+		(select transaction_id from transactions where sender = ? and state = ?)`, userId, accountId, TransactionAwaitingApproval).Error
+	if err != nil {
+		return fmt.Errorf("failed to drop approvals : %v", err)
+	}
+	return nil
+}
+
+func checkHasOwner(tx *gorm.DB, accountId string) error {
+	var owners int64
+	err := tx.Raw("select count(*) from account_holders where account_id = ? and role = ?", accountId, HolderOwner).Scan(&owners).Error
+	if err != nil {
+		return fmt.Errorf("failed to count account owners : %v", err)
+	}
+	if owners == 0 {
+		return errors.New("an account must keep at least one owner")
+	}
+	return nil
+}
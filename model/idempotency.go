@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different request")
+
+// IdempotencyKey remembers the first request sent with a client supplied key, so that a retry
+// gets the original response (and transaction id) instead of moving money a second time.
+type IdempotencyKey struct {
+	AccountId     string `gorm:"primaryKey"`
+	Key           string `gorm:"primaryKey;size:255"`
+	Fingerprint   string
+	TransactionId string
+	ResponseCode  int
+	ResponseBody  string
+	CreatedTime   time.Time
+}
+
+type IdempotencyModel struct {
+	DB *gorm.DB
+}
+
+func NewIdempotencyModel(db *gorm.DB) *IdempotencyModel {
+	return &IdempotencyModel{
+		DB: db,
+	}
+}
+
+// Reserve stores record unless the key is already known for this account, in which case the
+// stored record is returned. A known key with another fingerprint returns ErrIdempotencyKeyReused.
+func (m *IdempotencyModel) Reserve(record *IdempotencyKey) (*IdempotencyKey, bool, error) {
+	record.CreatedTime = time.Now()
+
+	result := m.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if err := result.Error; err != nil {
+		return nil, false, fmt.Errorf("failed to save idempotency key : %v", err)
+	}
+	if result.RowsAffected > 0 {
+		return record, false, nil
+	}
+
+	var existing IdempotencyKey
+	err := m.DB.Where("account_id = ? and key = ?", record.AccountId, record.Key).First(&existing).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotency key : %v", err)
+	}
+
+	if existing.Fingerprint != record.Fingerprint {
+		return nil, true, ErrIdempotencyKeyReused
+	}
+	return &existing, true, nil
+}
+
+// SaveResponse stores the response of the request which reserved record, unless one was already
+// stored : the first answer is the one replayed.
+func (m *IdempotencyModel) SaveResponse(record *IdempotencyKey, code int, body string) error {
+	result := m.DB.Exec("update idempotency_keys set response_code = ?, response_body = ? where account_id = ? and key = ? and response_code = 0",
+		code, body, record.AccountId, record.Key)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to save idempotent response : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	record.ResponseCode = code
+	record.ResponseBody = body
+	return nil
+}
+
+// Release forgets record while it has no response, so that the key can be used again.
+func (m *IdempotencyModel) Release(record *IdempotencyKey) error {
+	err := m.DB.Exec("delete from idempotency_keys where account_id = ? and key = ? and response_code = 0", record.AccountId, record.Key).Error
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key : %v", err)
+	}
+	return nil
+}
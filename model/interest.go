@@ -0,0 +1,265 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultProduct is the product of new accounts.
+const DefaultProduct = "current"
+
+// microsPerUnit is the precision of accruals : millionths of the minor unit of the currency.
+const microsPerUnit = 1000000
+
+// RateBand applies an annual Rate (percent) to the part of a balance above From, up to the From
+// of the next band.
+type RateBand struct {
+	From money.Money
+	Rate *big.Rat
+}
+
+var (
+	interestProducts = map[string][]RateBand{
+		DefaultProduct: {},
+	}
+	overdraftRate          = new(big.Rat)
+	interestDayCount int64 = 365
+)
+
+// SetInterestRates sets the rate table of each product, the rate of overdrafts and the number of
+// days in a year.
+func SetInterestRates(products map[string][]RateBand, overdraft *big.Rat, dayCount int) {
+	interestProducts = products
+	overdraftRate = overdraft
+	interestDayCount = int64(dayCount)
+}
+
+func IsValidProduct(product string) bool {
+	_, ok := interestProducts[product]
+	return ok
+}
+
+// perDayMicros converts an amount times a rate in percent per year to micros per day, rounded
+// towards zero.
+func perDayMicros(total *big.Rat) int64 {
+	total.Mul(total, big.NewRat(microsPerUnit, 100*interestDayCount))
+	return new(big.Int).Quo(total.Num(), total.Denom()).Int64()
+}
+
+// dailyInterestMicros returns the interest of one day on balance, in millionths of its minor
+// unit : earned on a positive balance, negative when charged on an overdraft.
+func dailyInterestMicros(balance money.Money, bands []RateBand) int64 {
+	if balance.IsNegative() {
+		owed := new(big.Rat).SetInt64(-balance.Units)
+		return -perDayMicros(owed.Mul(owed, overdraftRate))
+	}
+
+	total := new(big.Rat)
+	for i, band := range bands {
+		if !band.From.SameCurrency(balance) || balance.Units <= band.From.Units {
+			continue
+		}
+
+		upper := balance.Units
+		if i+1 < len(bands) && bands[i+1].From.Units < upper {
+			upper = bands[i+1].From.Units
+		}
+
+		portion := new(big.Rat).SetInt64(upper - band.From.Units)
+		total.Add(total, portion.Mul(portion, band.Rate))
+	}
+
+	return perDayMicros(total)
+}
+
+// InterestAccrual is the interest earned by an account on one day, on its balance at the end of
+// that day. It is paid by the Interest transaction TransactionId, blank until capitalised.
+type InterestAccrual struct {
+	AccountId     string    `gorm:"primaryKey"`
+	Date          time.Time `gorm:"primaryKey;type:date"`
+	Product       string
+	Balance       money.Money `gorm:"embedded;embeddedPrefix:balance_"`
+	AmountMicros  int64
+	TransactionId string `gorm:"index"`
+	// CarriedMicros is what the transaction paying the accrual left unpaid, the part of the
+	// interest below the minor unit, until CarryTransactionId pays it.
+	CarriedMicros      int64
+	CarryTransactionId string
+	CreatedTime        time.Time
+}
+
+// UnpaidInterest is the interest accrued by an account and not paid yet, negative when the
+// account owes overdraft interest.
+type UnpaidInterest struct {
+	AccountId   string
+	Currency    string
+	TotalMicros int64
+}
+
+func (u UnpaidInterest) Amount() money.Money {
+	return money.New(u.TotalMicros/microsPerUnit, u.Currency)
+}
+
+// InterestMicros converts an amount of interest to the precision of accruals.
+func InterestMicros(amount money.Money) int64 {
+	return amount.Units * microsPerUnit
+}
+
+type InterestModel struct {
+	DB *gorm.DB
+}
+
+func NewInterestModel(db *gorm.DB) *InterestModel {
+	return &InterestModel{
+		DB: db,
+	}
+}
+
+func (a *AccountModel) SetAccountProduct(accountId, product string) error {
+	if !IsValidProduct(product) {
+		return fmt.Errorf("unknown product : %s", product)
+	}
+
+	result := a.DB.Exec("update accounts set product = ? where account_id = ?", product, accountId)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to set product's account : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("account doesn't exist")
+	}
+	return nil
+}
+
+// Accrue records the interest of date for every open account with a balance at the end of that
+// day, computed from the ledger : earned when positive, charged when negative. Days already accrued are left as they are, so running
+// it again for the same date changes nothing. It returns the number of new accruals.
+func (i *InterestModel) Accrue(date time.Time) (int, error) {
+	day := Day(date)
+
+	var balances []struct {
+		AccountId string
+		Product   string
+		Currency  string
+		Units     int64
+	}
+	err := i.DB.Raw(`select a.account_id, a.product, a.balance_currency as currency, coalesce(sum(p.amount_units), 0) as units
+		from accounts a join postings p on p.account_id = a.account_id and p.created_time < ?
+		where a.state <> ? and not exists (select 1 from interest_accruals i where i.account_id = a.account_id and i.date = ?)
+		group by a.account_id, a.product, a.balance_currency`, day.AddDate(0, 0, 1), AccountClosed, day).Scan(&balances).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balances : %v", err)
+	}
+
+	var accruals []InterestAccrual
+	for _, b := range balances {
+		balance := money.New(b.Units, b.Currency)
+		if balance.IsZero() {
+			continue
+		}
+		accruals = append(accruals, InterestAccrual{
+			AccountId:    b.AccountId,
+			Date:         day,
+			Product:      b.Product,
+			Balance:      balance,
+			AmountMicros: dailyInterestMicros(balance, interestProducts[b.Product]),
+			CreatedTime:  time.Now(),
+		})
+	}
+
+	if len(accruals) == 0 {
+		return 0, nil
+	}
+
+	result := i.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(accruals, 500)
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to save accruals : %v", err)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// unpaidAccruals selects the accruals not paid by a completed or pending transaction, so the interest
+// of a rejected capitalisation is paid with the next one.
+const unpaidAccruals = `(i.transaction_id = '' or exists (select 1 from transactions t
+	where t.transaction_id = i.transaction_id and t.state in ('` + TransactionRejected + `', '` + TransactionFailed + `')))`
+
+// carriedAccruals selects the paid accruals carrying a remainder no transaction paid yet.
+const carriedAccruals = `(i.carried_micros <> 0 and not ` + unpaidAccruals + ` and (i.carry_transaction_id = '' or exists (select 1 from transactions t
+	where t.transaction_id = i.carry_transaction_id and t.state in ('` + TransactionRejected + `', '` + TransactionFailed + `'))))`
+
+// unpaidMicros sums the unpaid amounts and remainders of the accruals selected by
+// unpaidAccruals or carriedAccruals.
+const unpaidMicros = `coalesce(sum(case when ` + unpaidAccruals + ` then i.amount_micros else i.carried_micros end), 0)`
+
+// Unpaid returns, by account, the interest accrued before the given date and not paid yet.
+func (i *InterestModel) Unpaid(before time.Time) ([]UnpaidInterest, error) {
+	var unpaid []UnpaidInterest
+	err := i.DB.Raw(`select i.account_id, i.balance_currency as currency, `+unpaidMicros+` as total_micros
+		from interest_accruals i
+		where i.date < ? and (`+unpaidAccruals+` or `+carriedAccruals+`)
+		group by i.account_id, i.balance_currency`, Day(before)).Scan(&unpaid).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unpaid interest : %v", err)
+	}
+	return unpaid, nil
+}
+
+// MarkPaid links the unpaid accruals of an account before the given date, and the remainders
+// they carried, to the transaction paying paidMicros of them. What it doesn't pay, the part below
+// the minor unit, is carried by the latest of these accruals to the next payment.
+func (i *InterestModel) MarkPaid(accountId string, before time.Time, transactionId string, paidMicros int64) error {
+	return i.DB.Transaction(func(tx *gorm.DB) error {
+		var totals []int64
+		err := tx.Raw(`select `+unpaidMicros+` from interest_accruals i
+			where i.account_id = ? and i.date < ? and (`+unpaidAccruals+` or `+carriedAccruals+`)`, accountId, Day(before)).Scan(&totals).Error
+		if err != nil {
+			return fmt.Errorf("failed to get unpaid interest : %v", err)
+		}
+		if len(totals) == 0 {
+			return nil
+		}
+
+		err = tx.Exec(`update interest_accruals i set carry_transaction_id = ?
+			where i.account_id = ? and i.date < ? and `+carriedAccruals, transactionId, accountId, Day(before)).Error
+		if err != nil {
+			return fmt.Errorf("failed to mark interest as paid : %v", err)
+		}
+
+		err = tx.Exec(`update interest_accruals i set transaction_id = ?, carried_micros = 0, carry_transaction_id = ''
+			where i.account_id = ? and i.date < ? and `+unpaidAccruals, transactionId, accountId, Day(before)).Error
+		if err != nil {
+			return fmt.Errorf("failed to mark interest as paid : %v", err)
+		}
+
+		remainder := totals[0] - paidMicros
+		if remainder == 0 {
+			return nil
+		}
+		err = tx.Exec(`update interest_accruals set carried_micros = ?, carry_transaction_id = ''
+			where account_id = ? and date = (select max(date) from interest_accruals
+				where account_id = ? and date < ? and (transaction_id = ? or carry_transaction_id = ?))`,
+			remainder, accountId, accountId, Day(before), transactionId, transactionId).Error
+		if err != nil {
+			return fmt.Errorf("failed to carry unpaid interest : %v", err)
+		}
+		return nil
+	})
+}
+
+// Accrued returns the interest an account accrued and wasn't paid yet.
+func (i *InterestModel) Accrued(accountId string) (*UnpaidInterest, error) {
+	unpaid := UnpaidInterest{AccountId: accountId, Currency: money.DefaultCurrency}
+	err := i.DB.Raw(`select i.account_id, i.balance_currency as currency, `+unpaidMicros+` as total_micros
+		from interest_accruals i
+		where i.account_id = ? and (`+unpaidAccruals+` or `+carriedAccruals+`)
+		group by i.account_id, i.balance_currency`, accountId).Scan(&unpaid).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accrued interest : %v", err)
+	}
+	return &unpaid, nil
+}
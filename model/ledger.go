@@ -0,0 +1,339 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Internal ledger accounts. They have no row in the accounts table, they only exist as the
+// counterpart of customer postings so that every journal entry sums to zero.
+const (
+	internalAccountPrefix = "internal:"
+
+	CashAccountId           = internalAccountPrefix + "cash"                // money entering the bank through deposits
+	WithdrawalClearingId    = internalAccountPrefix + "withdrawal-clearing" // money leaving the bank through withdrawals
+	OpeningBalanceAccountId = internalAccountPrefix + "opening-balance"     // initial balances granted at registration
+	FeeIncomeAccountId      = internalAccountPrefix + "fee-income"          // fees charged on transactions
+	InterestExpenseId       = internalAccountPrefix + "interest-expense"    // interest paid to accounts
+	InterestIncomeId        = internalAccountPrefix + "interest-income"     // overdraft interest charged to accounts
+)
+
+// Kinds of journal entries written besides the main entry of a transaction, whose kind is blank.
+const (
+	EntryKindFee = "FEE"
+)
+
+// JournalEntry groups the postings written for one business event (a deposit, a transfer, ...).
+type JournalEntry struct {
+	EntryId       string `gorm:"primaryKey"`
+	TransactionId string `gorm:"index"`
+	Kind          string
+	Description   string
+	CreatedTime   time.Time
+}
+
+// Posting is one line of a journal entry. A positive amount increases the balance of AccountId,
+// a negative amount decreases it ; the postings of an entry always sum to zero.
+type Posting struct {
+	PostingId    int64       `gorm:"primaryKey;autoIncrement"`
+	EntryId      string      `gorm:"index"`
+	AccountId    string      `gorm:"index"`
+	Amount       money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	BalanceAfter *int64
+	CreatedTime  time.Time
+	// Charge marks a debit of the bank (e.g. overdraft interest) allowed below the account floor.
+	Charge bool `gorm:"-"`
+}
+
+type LedgerModel struct {
+	DB           *gorm.DB
+	AccountModel *AccountModel
+}
+
+func NewLedgerModel(db *gorm.DB, accountModel *AccountModel) *LedgerModel {
+	return &LedgerModel{
+		DB:           db,
+		AccountModel: accountModel,
+	}
+}
+
+func IsInternalAccount(accountId string) bool {
+	return strings.HasPrefix(accountId, internalAccountPrefix)
+}
+
+// CreditsSender tells whether a transaction type brings money to its sender, which is then the
+// only account involved (a deposit, interest).
+func CreditsSender(txType string) bool {
+	return txType == "Deposit" || txType == "Interest"
+}
+
+// TransactionPostings returns the balanced postings of a customer transaction.
+func TransactionPostings(tx *Transaction) ([]Posting, error) {
+	switch tx.Type {
+	case "Deposit":
+		return []Posting{
+			{AccountId: tx.Sender, Amount: tx.Amount},
+			{AccountId: CashAccountId, Amount: tx.Amount.Neg()},
+		}, nil
+	case "Withdraw":
+		return []Posting{
+			{AccountId: tx.Sender, Amount: tx.Amount.Neg()},
+			{AccountId: WithdrawalClearingId, Amount: tx.Amount},
+		}, nil
+	case "Transfer":
+		return []Posting{
+			{AccountId: tx.Sender, Amount: tx.Amount.Neg()},
+			{AccountId: tx.Receiver, Amount: tx.Amount},
+		}, nil
+	case "Interest":
+		return []Posting{
+			{AccountId: tx.Sender, Amount: tx.Amount},
+			{AccountId: InterestExpenseId, Amount: tx.Amount.Neg()},
+		}, nil
+	case "OverdraftInterest":
+		return []Posting{
+			{AccountId: tx.Sender, Amount: tx.Amount.Neg(), Charge: true},
+			{AccountId: InterestIncomeId, Amount: tx.Amount},
+		}, nil
+	case "Reversal":
+		return []Posting{
+			{AccountId: tx.Sender, Amount: tx.Amount.Neg(), Charge: tx.Forced},
+			{AccountId: tx.Receiver, Amount: tx.Amount},
+		}, nil
+	}
+	return nil, Reject(ReasonInvalidTransaction, "unknown transaction type : %s", tx.Type)
+}
+
+func checkBalanced(postings []Posting) error {
+	if len(postings) < 2 {
+		return errors.New("a journal entry needs at least two postings")
+	}
+
+	sums := map[string]int64{}
+	for _, p := range postings {
+		if p.Amount.Currency == "" {
+			return errors.New("posting currency must not be blank")
+		}
+		sums[strings.ToUpper(p.Amount.Currency)] += p.Amount.Units
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("journal entry is not balanced : %s postings sum to %d", currency, sum)
+		}
+	}
+	return nil
+}
+
+// recordJournal writes an entry and its postings without touching account balances.
+func recordJournal(tx *gorm.DB, entry *JournalEntry, postings []Posting) error {
+	if err := checkBalanced(postings); err != nil {
+		return err
+	}
+
+	if entry.EntryId == "" {
+		entry.EntryId = uuid.NewString()
+	}
+	entry.CreatedTime = time.Now()
+
+	err := tx.Create(entry).Error
+	if err != nil {
+		return fmt.Errorf("failed to save journal entry : %v", err)
+	}
+
+	for i := range postings {
+		postings[i].EntryId = entry.EntryId
+		postings[i].CreatedTime = entry.CreatedTime
+	}
+
+	err = tx.Create(&postings).Error
+	if err != nil {
+		return fmt.Errorf("failed to save postings : %v", err)
+	}
+
+	return nil
+}
+
+// auditBalanceChanges records the balances of the customer accounts of entry before and after
+// its postings, in the transaction writing them. The audit log is locked until tx commits : it
+// must be the last statement of tx.
+func auditBalanceChanges(tx *gorm.DB, entry *JournalEntry, postings []Posting) error {
+	before, after := map[string]money.Money{}, map[string]money.Money{}
+	for _, p := range postings {
+		if IsInternalAccount(p.AccountId) || p.BalanceAfter == nil {
+			continue
+		}
+		after[p.AccountId] = money.New(*p.BalanceAfter, p.Amount.Currency)
+		before[p.AccountId] = money.New(*p.BalanceAfter-p.Amount.Units, p.Amount.Currency)
+	}
+	if len(after) == 0 {
+		return nil
+	}
+
+	audit := &AuditEntry{
+		Event:      AuditBalanceChanged,
+		TargetType: "journal_entry",
+		TargetId:   entry.EntryId,
+		Before:     AuditValue(before),
+		After:      AuditValue(after),
+		Detail:     entry.Description,
+	}
+	if entry.TransactionId != "" {
+		audit.TargetType = "transaction"
+		audit.TargetId = entry.TransactionId
+
+		// the request which created the transaction
+		var requestIds []string
+		err := tx.Raw("select request_id from transactions where transaction_id = ?", entry.TransactionId).Scan(&requestIds).Error
+		if err != nil {
+			return fmt.Errorf("failed to get transaction : %v", err)
+		}
+		if len(requestIds) > 0 {
+			audit.RequestId = requestIds[0]
+		}
+	}
+	return appendAudit(tx, audit)
+}
+
+// Post writes a balanced journal entry and applies its customer postings to the accounts.balance
+// projection. It must run inside dbTx so that the projection and the journal never diverge. The
+// balance changes are audited by AuditPostings.
+func (l *LedgerModel) Post(entry *JournalEntry, postings []Posting, dbTx *gorm.DB) error {
+	if err := checkBalanced(postings); err != nil {
+		return err
+	}
+
+	accountIds := make([]string, 0, len(postings))
+	for _, p := range postings {
+		accountIds = append(accountIds, p.AccountId)
+	}
+	if err := l.AccountModel.LockAccounts(accountIds, dbTx); err != nil {
+		return err
+	}
+
+	for i := range postings {
+		p := &postings[i]
+		if IsInternalAccount(p.AccountId) {
+			continue
+		}
+
+		err := l.AccountModel.CheckAccountMovement(p.AccountId, p.Amount.IsNegative(), dbTx)
+		if err != nil {
+			return err
+		}
+
+		if p.Amount.IsNegative() && p.Charge {
+			err = l.AccountModel.SaveNewBalanceWithCharge(p.Amount.Neg(), p.AccountId, dbTx)
+		} else if p.Amount.IsNegative() {
+			err = l.AccountModel.SaveNewBalanceWithNegativeAmount(p.Amount.Neg(), p.AccountId, dbTx)
+		} else {
+			err = l.AccountModel.SaveNewBalanceWithPositiveAmount(p.Amount, p.AccountId, dbTx)
+		}
+		if err != nil {
+			return err
+		}
+
+		var balanceAfter int64
+		err = dbTx.Raw("select balance_units from accounts where account_id = ?", p.AccountId).Scan(&balanceAfter).Error
+		if err != nil {
+			return fmt.Errorf("failed to read new balance : %v", err)
+		}
+		p.BalanceAfter = &balanceAfter
+	}
+
+	return recordJournal(dbTx, entry, postings)
+}
+
+// AuditPostings records the balance changes of the entries posted in dbTx. Appending to the audit
+// log locks it until dbTx commits, so it is called once everything else of dbTx is done.
+func (l *LedgerModel) AuditPostings(entry *JournalEntry, postings []Posting, dbTx *gorm.DB) error {
+	return auditBalanceChanges(dbTx, entry, postings)
+}
+
+// BackfillOpeningBalances gives accounts created before the ledger existed an opening entry
+// equal to their current balance, so that the projection can be verified against postings.
+func (l *LedgerModel) BackfillOpeningBalances() error {
+	var accounts []Account
+	err := l.DB.Raw("select account_id, balance_units, balance_currency from accounts a where not exists (select 1 from postings p where p.account_id = a.account_id)").
+		Scan(&accounts).Error
+	if err != nil {
+		return fmt.Errorf("failed to find accounts without postings : %v", err)
+	}
+
+	for _, account := range accounts {
+		if account.Balance.Currency == "" {
+			account.Balance.Currency = money.DefaultCurrency
+		}
+		balance := account.Balance.Units
+		err := l.DB.Transaction(func(tx *gorm.DB) error {
+			entry := &JournalEntry{Description: "Opening balance"}
+			postings := []Posting{
+				{AccountId: account.AccountId, Amount: account.Balance, BalanceAfter: &balance},
+				{AccountId: OpeningBalanceAccountId, Amount: account.Balance.Neg()},
+			}
+			if err := recordJournal(tx, entry, postings); err != nil {
+				return err
+			}
+			return auditBalanceChanges(tx, entry, postings)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks the ledger invariants : every entry is balanced, all postings sum to zero and
+// every customer balance equals the sum of its postings. It returns one line per violation.
+func (l *LedgerModel) Verify() ([]string, error) {
+	var problems []string
+
+	var totals []struct {
+		Currency string
+		Total    int64
+	}
+	err := l.DB.Raw("select amount_currency as currency, coalesce(sum(amount_units), 0) as total from postings group by amount_currency").Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum postings : %v", err)
+	}
+	for _, t := range totals {
+		if t.Total != 0 {
+			problems = append(problems, fmt.Sprintf("postings in %s sum to %d instead of 0", t.Currency, t.Total))
+		}
+	}
+
+	var unbalanced []struct {
+		EntryId string
+		Total   int64
+	}
+	err = l.DB.Raw("select entry_id, sum(amount_units) as total from postings group by entry_id, amount_currency having sum(amount_units) <> 0").Scan(&unbalanced).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check journal entries : %v", err)
+	}
+	for _, e := range unbalanced {
+		problems = append(problems, fmt.Sprintf("journal entry %s is unbalanced by %d", e.EntryId, e.Total))
+	}
+
+	var drifted []struct {
+		AccountId string
+		Balance   int64
+		Posted    int64
+	}
+	err = l.DB.Raw(`select a.account_id, a.balance_units as balance, coalesce(sum(p.amount_units), 0) as posted
+		from accounts a left join postings p on p.account_id = a.account_id
+		group by a.account_id, a.balance_units
+		having a.balance_units <> coalesce(sum(p.amount_units), 0)`).Scan(&drifted).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account balances : %v", err)
+	}
+	for _, d := range drifted {
+		problems = append(problems, fmt.Sprintf("account %s has balance %d but its postings sum to %d", d.AccountId, d.Balance, d.Posted))
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"account-management/money"
+	"testing"
+)
+
+// The ledger invariants checked by Verify hold as long as every entry written is balanced :
+// these tests cover the postings of each transaction type and the check refusing the others.
+
+func TestCheckBalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []Posting
+		wantErr  bool
+	}{
+		{"balanced", []Posting{
+			{AccountId: "a", Amount: money.New(-100, "USD")},
+			{AccountId: "b", Amount: money.New(100, "USD")},
+		}, false},
+		{"balanced per currency", []Posting{
+			{AccountId: "a", Amount: money.New(-100, "USD")},
+			{AccountId: "b", Amount: money.New(100, "usd")},
+			{AccountId: "a", Amount: money.New(50, "EUR")},
+			{AccountId: "b", Amount: money.New(-50, "EUR")},
+		}, false},
+		{"unbalanced", []Posting{
+			{AccountId: "a", Amount: money.New(-100, "USD")},
+			{AccountId: "b", Amount: money.New(99, "USD")},
+		}, true},
+		{"balanced across currencies only", []Posting{
+			{AccountId: "a", Amount: money.New(-100, "USD")},
+			{AccountId: "b", Amount: money.New(100, "EUR")},
+		}, true},
+		{"blank currency", []Posting{
+			{AccountId: "a", Amount: money.New(-100, "")},
+			{AccountId: "b", Amount: money.New(100, "")},
+		}, true},
+		{"single posting", []Posting{
+			{AccountId: "a", Amount: money.New(0, "USD")},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		err := checkBalanced(tt.postings)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s : checkBalanced() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransactionPostings(t *testing.T) {
+	amount := money.New(12345, "USD")
+	tests := []struct {
+		tx Transaction
+		// sender is the change of the balance of the sender
+		sender int64
+	}{
+		{Transaction{Type: "Deposit", Sender: "a", Amount: amount}, 12345},
+		{Transaction{Type: "Withdraw", Sender: "a", Amount: amount}, -12345},
+		{Transaction{Type: "Transfer", Sender: "a", Receiver: "b", Amount: amount}, -12345},
+		{Transaction{Type: "Interest", Sender: "a", Amount: amount}, 12345},
+		{Transaction{Type: "OverdraftInterest", Sender: "a", Amount: amount}, -12345},
+		{Transaction{Type: "Reversal", Sender: "a", Receiver: "b", Amount: amount}, -12345},
+	}
+
+	for _, tt := range tests {
+		postings, err := TransactionPostings(&tt.tx)
+		if err != nil {
+			t.Errorf("%s : %v", tt.tx.Type, err)
+			continue
+		}
+		if err := checkBalanced(postings); err != nil {
+			t.Errorf("%s : %v", tt.tx.Type, err)
+		}
+
+		var sender int64
+		for _, p := range postings {
+			if p.AccountId == tt.tx.Sender {
+				sender += p.Amount.Units
+			}
+		}
+		if sender != tt.sender {
+			t.Errorf("%s : sender moves by %d, want %d", tt.tx.Type, sender, tt.sender)
+		}
+	}
+
+	if _, err := TransactionPostings(&Transaction{Type: "Gift", Sender: "a", Amount: amount}); err == nil {
+		t.Error("an unknown type must be rejected")
+	}
+}
+
+func TestFeePostings(t *testing.T) {
+	if postings := FeePostings(&Transaction{Sender: "a", Fee: money.New(0, "USD")}); postings != nil {
+		t.Errorf("no fee : got %v, want no postings", postings)
+	}
+
+	postings := FeePostings(&Transaction{Sender: "a", Fee: money.New(150, "USD")})
+	if err := checkBalanced(postings); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range postings {
+		if p.AccountId == "a" && p.Amount.Units != -150 {
+			t.Errorf("sender charged %d, want -150", p.Amount.Units)
+		}
+	}
+}
+
+func TestNewReversal(t *testing.T) {
+	amount := money.New(500, "USD")
+	tests := []struct {
+		original Transaction
+		sender   string
+		receiver string
+	}{
+		{Transaction{Type: "Transfer", Sender: "a", Receiver: "b", Amount: amount}, "b", "a"},
+		{Transaction{Type: "Deposit", Sender: "a", Amount: amount}, "a", CashAccountId},
+		{Transaction{Type: "Withdraw", Sender: "a", Amount: amount}, WithdrawalClearingId, "a"},
+	}
+
+	for _, tt := range tests {
+		reversal, err := NewReversal(&tt.original, amount, "test", false)
+		if err != nil {
+			t.Errorf("%s : %v", tt.original.Type, err)
+			continue
+		}
+		if reversal.Sender != tt.sender || reversal.Receiver != tt.receiver {
+			t.Errorf("%s : reversal from %s to %s, want from %s to %s", tt.original.Type, reversal.Sender, reversal.Receiver, tt.sender, tt.receiver)
+		}
+
+		// the original and its reversal leave every account as it was
+		originalPostings, _ := TransactionPostings(&tt.original)
+		reversalPostings, _ := TransactionPostings(reversal)
+		moved := map[string]int64{}
+		for _, p := range append(originalPostings, reversalPostings...) {
+			moved[p.AccountId] += p.Amount.Units
+		}
+		for accountId, units := range moved {
+			if units != 0 {
+				t.Errorf("%s : %s moved by %d after the reversal", tt.original.Type, accountId, units)
+			}
+		}
+	}
+
+	if _, err := NewReversal(&Transaction{Type: "Reversal", Sender: "a", Receiver: "b", Amount: amount}, amount, "test", false); err == nil {
+		t.Error("a reversal must not be reversible")
+	}
+}
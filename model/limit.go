@@ -0,0 +1,300 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultTier is the tier of new accounts.
+const DefaultTier = "standard"
+
+// Limits caps the amounts of one transaction type ; a nil amount means no limit. Daily and
+// monthly limits apply to the completed transactions of the current UTC day and month.
+type Limits struct {
+	PerTransaction *money.Money
+	Daily          *money.Money
+	Monthly        *money.Money
+}
+
+// tierLimits holds the default limits of each tier, by transaction type.
+var tierLimits = map[string]map[string]Limits{
+	DefaultTier: {},
+}
+
+func SetTierLimits(limits map[string]map[string]Limits) {
+	tierLimits = limits
+}
+
+func IsValidTier(tier string) bool {
+	_, ok := tierLimits[tier]
+	return ok
+}
+
+// AccountLimit overrides the tier limits of one transaction type for one account.
+type AccountLimit struct {
+	AccountId           string `gorm:"primaryKey"`
+	Type                string `gorm:"primaryKey"`
+	PerTransactionUnits *int64
+	DailyUnits          *int64
+	MonthlyUnits        *int64
+	Currency            string `gorm:"size:3"`
+	UpdatedBy           string
+	UpdatedTime         time.Time
+}
+
+func unitsOf(m *money.Money) *int64 {
+	if m == nil {
+		return nil
+	}
+	units := m.Units
+	return &units
+}
+
+func moneyOf(units *int64, currency string) *money.Money {
+	if units == nil {
+		return nil
+	}
+	m := money.New(*units, currency)
+	return &m
+}
+
+func (l *AccountLimit) Limits() Limits {
+	return Limits{
+		PerTransaction: moneyOf(l.PerTransactionUnits, l.Currency),
+		Daily:          moneyOf(l.DailyUnits, l.Currency),
+		Monthly:        moneyOf(l.MonthlyUnits, l.Currency),
+	}
+}
+
+// LimitUsage is the state of the limits of one transaction type of an account.
+type LimitUsage struct {
+	Type   string
+	Tier   string
+	Source string // "tier" or "override"
+	Limits
+	UsedToday          money.Money
+	UsedThisMonth      money.Money
+	RemainingAllowance *money.Money // nil when unlimited
+}
+
+type LimitModel struct {
+	DB *gorm.DB
+}
+
+func NewLimitModel(db *gorm.DB) *LimitModel {
+	return &LimitModel{
+		DB: db,
+	}
+}
+
+func (a *AccountModel) SetAccountTier(accountId, tier string) error {
+	if !IsValidTier(tier) {
+		return fmt.Errorf("unknown tier : %s", tier)
+	}
+
+	result := a.DB.Exec("update accounts set tier = ? where account_id = ?", tier, accountId)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to set tier's account : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("account doesn't exist")
+	}
+	return nil
+}
+
+// Override returns the limits override of a transaction type of an account, nil when it has none.
+func (l *LimitModel) Override(accountId, txType string) (*AccountLimit, error) {
+	var overrides []AccountLimit
+	err := l.DB.Where("account_id = ? and type = ?", accountId, txType).Find(&overrides).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get limits : %v", err)
+	}
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	return &overrides[0], nil
+}
+
+// SetOverride replaces the limits of a transaction type of an account by override's.
+func (l *LimitModel) SetOverride(override *AccountLimit) error {
+	override.UpdatedTime = time.Now()
+
+	err := l.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(override).Error
+	if err != nil {
+		return fmt.Errorf("failed to save limits : %v", err)
+	}
+	return nil
+}
+
+// DeleteOverride puts a transaction type of an account back on the limits of its tier.
+func (l *LimitModel) DeleteOverride(accountId, txType string) error {
+	result := l.DB.Exec("delete from account_limits where account_id = ? and type = ?", accountId, txType)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to delete limits : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("account has no limits override for this transaction type")
+	}
+	return nil
+}
+
+func (l *LimitModel) effectiveLimits(db *gorm.DB, accountId, tier, txType string) (Limits, string, error) {
+	var overrides []AccountLimit
+	err := db.Where("account_id = ? and type = ?", accountId, txType).Limit(1).Find(&overrides).Error
+	if err != nil {
+		return Limits{}, "", fmt.Errorf("failed to get limits : %v", err)
+	}
+	if len(overrides) > 0 {
+		return overrides[0].Limits(), "override", nil
+	}
+	return tierLimits[tier][txType], "tier", nil
+}
+
+// used sums the completed transactions of a type sent by accountId since the given time.
+func (l *LimitModel) used(db *gorm.DB, accountId, txType string, since time.Time) (int64, error) {
+	var total int64
+	err := db.Raw("select coalesce(sum(amount_units), 0) from transactions where sender = ? and type = ? and state = ? and completed_time >= ?",
+		accountId, txType, TransactionCompleted, since).Scan(&total).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum transactions : %v", err)
+	}
+	return total, nil
+}
+
+func (l *LimitModel) usage(db *gorm.DB, accountId, tier, txType, currency string, now time.Time) (*LimitUsage, error) {
+	limits, source, err := l.effectiveLimits(db, accountId, tier, txType)
+	if err != nil {
+		return nil, err
+	}
+
+	today := Day(now)
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	usedToday, err := l.used(db, accountId, txType, today)
+	if err != nil {
+		return nil, err
+	}
+	usedThisMonth, err := l.used(db, accountId, txType, month)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &LimitUsage{
+		Type:          txType,
+		Tier:          tier,
+		Source:        source,
+		Limits:        limits,
+		UsedToday:     money.New(usedToday, currency),
+		UsedThisMonth: money.New(usedThisMonth, currency),
+	}
+
+	remaining := func(limit *money.Money, used int64) {
+		if limit == nil {
+			return
+		}
+		left := limit.Units - used
+		if left < 0 {
+			left = 0
+		}
+		if usage.RemainingAllowance == nil || left < usage.RemainingAllowance.Units {
+			usage.RemainingAllowance = moneyOf(&left, currency)
+		}
+	}
+	remaining(limits.PerTransaction, 0)
+	remaining(limits.Daily, usedToday)
+	remaining(limits.Monthly, usedThisMonth)
+
+	return usage, nil
+}
+
+// Usage returns, for every transaction type with limits, the limits of the account, what it
+// used of them and what remains.
+func (l *LimitModel) Usage(accountId string) ([]LimitUsage, error) {
+	var accounts []Account
+	err := l.DB.Raw("select account_id, tier, balance_currency from accounts where account_id = ?", accountId).Scan(&accounts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account : %v", err)
+	}
+	if len(accounts) == 0 {
+		return nil, errors.New("account doesn't exist")
+	}
+	account := accounts[0]
+
+	types := map[string]bool{}
+	for txType := range tierLimits[account.Tier] {
+		types[txType] = true
+	}
+	var overridden []string
+	err = l.DB.Raw("select type from account_limits where account_id = ?", accountId).Scan(&overridden).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get limits : %v", err)
+	}
+	for _, txType := range overridden {
+		types[txType] = true
+	}
+
+	var sorted []string
+	for txType := range types {
+		sorted = append(sorted, txType)
+	}
+	sort.Strings(sorted)
+
+	now := time.Now()
+	usages := make([]LimitUsage, 0, len(sorted))
+	for _, txType := range sorted {
+		usage, err := l.usage(l.DB, accountId, account.Tier, txType, account.Balance.Currency, now)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *usage)
+	}
+	return usages, nil
+}
+
+// Check rejects tx with LIMIT_EXCEEDED when it would break a limit of its sender. The sender row
+// is locked for the rest of dbTx so that concurrent transactions of the account are counted
+// one after the other ; it must run in the transaction that posts tx.
+func (l *LimitModel) Check(tx *Transaction, dbTx *gorm.DB) error {
+	var tiers []string
+	err := dbTx.Raw("select tier from accounts where account_id = ? for update", tx.Sender).Scan(&tiers).Error
+	if err != nil {
+		return fmt.Errorf("failed to get tier's account : %v", err)
+	}
+	if len(tiers) == 0 {
+		return Reject(ReasonAccountNotFound, "account %s doesn't exist", tx.Sender)
+	}
+
+	usage, err := l.usage(dbTx, tx.Sender, tiers[0], tx.Type, tx.Amount.Currency, time.Now())
+	if err != nil {
+		return err
+	}
+
+	checks := []struct {
+		name  string
+		limit *money.Money
+		used  int64
+	}{
+		{"per transaction", usage.PerTransaction, 0},
+		{"daily", usage.Daily, usage.UsedToday.Units},
+		{"monthly", usage.Monthly, usage.UsedThisMonth.Units},
+	}
+	for _, check := range checks {
+		if check.limit == nil {
+			continue
+		}
+		if !check.limit.SameCurrency(tx.Amount) {
+			return Reject(ReasonCurrencyMismatch, "limits of account %s are in %s, not %s", tx.Sender, check.limit.Currency, tx.Amount.Currency)
+		}
+		if check.used+tx.Amount.Units > check.limit.Units {
+			return Reject(ReasonLimitExceeded, "%s %s limit of %s exceeded, remaining allowance %s",
+				check.name, tx.Type, check.limit, usage.RemainingAllowance)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Results of a confirmation of payee : the name given by the payer matches the name of the
+// account, is close to it (a typo, initials, another order) or doesn't match. The name can't be
+// checked when no holder of the account gave one.
+const (
+	PayeeMatch       = "MATCH"
+	PayeeCloseMatch  = "CLOSE_MATCH"
+	PayeeNoMatch     = "NO_MATCH"
+	PayeeUnavailable = "UNAVAILABLE"
+)
+
+// PayeeCheck is the result of confirming the name of the payee of AccountNumber. Name, the name
+// of the account, is only given back on a close match, for the payer to correct its own.
+type PayeeCheck struct {
+	AccountNumber string
+	AccountId     string
+	Result        string
+	Name          string
+}
+
+// titles are left out of the names compared.
+var titles = map[string]bool{"mr": true, "mrs": true, "ms": true, "miss": true, "dr": true}
+
+// ConfirmPayee compares name with the names of the holders able to operate the account numbered
+// number, and returns the best result. Holders who registered without a name are left out :
+// their username is never compared, so that account numbers can't be used to guess it.
+func (a *AccountModel) ConfirmPayee(number, name string) (*PayeeCheck, error) {
+	accountId, err := a.GetAccountIdByNumber(number)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("payee name must not be blank")
+	}
+
+	var names []string
+	err = a.DB.Raw(`select users.name from account_holders join users on users.user_id = account_holders.user_id
+		where account_holders.account_id = ? and account_holders.role in ? and users.name <> ''`, accountId, []string{HolderOwner, HolderHolder}).
+		Scan(&names).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account holders : %v", err)
+	}
+
+	check := &PayeeCheck{AccountNumber: NormalizeAccountNumber(number), AccountId: accountId, Result: PayeeNoMatch}
+	if len(names) == 0 {
+		check.Result = PayeeUnavailable
+		return check, nil
+	}
+	for _, holderName := range names {
+		switch MatchPayeeName(name, holderName) {
+		case PayeeMatch:
+			check.Result, check.Name = PayeeMatch, ""
+			return check, nil
+		case PayeeCloseMatch:
+			if check.Result == PayeeNoMatch {
+				check.Result, check.Name = PayeeCloseMatch, holderName
+			}
+		}
+	}
+	return check, nil
+}
+
+// MatchPayeeName compares the name given by a payer with the name of an account, ignoring case,
+// punctuation and titles.
+func MatchPayeeName(given, actual string) string {
+	g, a := nameWords(given), nameWords(actual)
+	if len(g) == 0 || len(a) == 0 {
+		return PayeeNoMatch
+	}
+	if strings.Join(g, " ") == strings.Join(a, " ") {
+		return PayeeMatch
+	}
+
+	// the same words in another order
+	sortedG, sortedA := append([]string{}, g...), append([]string{}, a...)
+	sort.Strings(sortedG)
+	sort.Strings(sortedA)
+	if strings.Join(sortedG, " ") == strings.Join(sortedA, " ") {
+		return PayeeCloseMatch
+	}
+
+	// a typo : a few letters apart
+	joinedG, joinedA := strings.Join(g, ""), strings.Join(a, "")
+	tolerance := len([]rune(joinedA)) / 6
+	if tolerance < 1 {
+		tolerance = 1
+	}
+	if editDistance(joinedG, joinedA) <= tolerance {
+		return PayeeCloseMatch
+	}
+
+	// initials for the first names, e.g. "J Smith" for "John Smith"
+	if len(g) == len(a) && g[len(g)-1] == a[len(a)-1] {
+		initials := true
+		for i := 0; i < len(g)-1; i++ {
+			initials = initials && []rune(g[i])[0] == []rune(a[i])[0]
+		}
+		if initials {
+			return PayeeCloseMatch
+		}
+	}
+
+	return PayeeNoMatch
+}
+
+func nameWords(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kept := words[:0]
+	for _, word := range words {
+		if !titles[word] {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,51 @@
+package model
+
+import "testing"
+
+func TestMatchPayeeName(t *testing.T) {
+	tests := []struct {
+		given  string
+		actual string
+		want   string
+	}{
+		{"Alice Martin", "Alice Martin", PayeeMatch},
+		{"alice  MARTIN", "Alice Martin", PayeeMatch},
+		{"Mrs. Alice Martin", "Alice Martin", PayeeMatch},
+		{"Alice-Martin", "Alice Martin", PayeeMatch},
+		{"Martin Alice", "Alice Martin", PayeeCloseMatch},
+		{"Alise Martin", "Alice Martin", PayeeCloseMatch},
+		{"A Martin", "Alice Martin", PayeeCloseMatch},
+		{"A. Martin", "Alice Martin", PayeeCloseMatch},
+		{"Bob Martin", "Alice Martin", PayeeNoMatch},
+		{"Alice Dupont", "Alice Martin", PayeeNoMatch},
+		{"Alice", "Alice Martin", PayeeNoMatch},
+		{"", "Alice Martin", PayeeNoMatch},
+		{"Mr", "Alice Martin", PayeeNoMatch},
+		{"Alice Martin", "", PayeeNoMatch},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPayeeName(tt.given, tt.actual); got != tt.want {
+			t.Errorf("MatchPayeeName(%q, %q) = %s, want %s", tt.given, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"alice", "alise", 1},
+		{"émile", "emile", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
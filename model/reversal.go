@@ -0,0 +1,126 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NewReversal builds the transaction moving amount back from the account original credited to the
+// one it debited ; internal accounts (cash, fee income, ...) may be either side. Sender is the
+// debited account, as for every other type.
+func NewReversal(original *Transaction, amount money.Money, description string, forced bool) (*Transaction, error) {
+	if original.Type == "Reversal" {
+		return nil, errors.New("a reversal can't be reversed")
+	}
+
+	postings, err := TransactionPostings(original)
+	if err != nil {
+		return nil, err
+	}
+
+	reversal := &Transaction{
+		TransactionId: uuid.NewString(),
+		Amount:        amount,
+		Type:          "Reversal",
+		ReversalOf:    original.TransactionId,
+		Description:   description,
+		Forced:        forced,
+	}
+	for _, p := range postings {
+		if p.Amount.IsPositive() {
+			reversal.Sender = p.AccountId
+		} else {
+			reversal.Receiver = p.AccountId
+		}
+	}
+	return reversal, nil
+}
+
+// lockReversible locks the transaction reversal reverses and checks that it is completed and
+// that its reversals in one of states, reversal excluded, leave room for reversal's amount. The
+// reversed transaction and the amount left to reverse before reversal are returned.
+func lockReversible(db *gorm.DB, reversal *Transaction, states ...string) (*Transaction, money.Money, error) {
+	var original Transaction
+	result := db.Raw("select * from transactions where transaction_id = ? for update", reversal.ReversalOf).Scan(&original)
+	if err := result.Error; err != nil {
+		return nil, money.Money{}, fmt.Errorf("failed to get reversed transaction : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, money.Money{}, Reject(ReasonInvalidTransaction, "reversed transaction %s doesn't exist", reversal.ReversalOf)
+	}
+	if original.State != TransactionCompleted {
+		return nil, money.Money{}, Reject(ReasonInvalidTransaction, "only completed transactions can be reversed, %s is %s", original.TransactionId, original.State)
+	}
+	if !reversal.Amount.SameCurrency(original.Amount) {
+		return nil, money.Money{}, Reject(ReasonCurrencyMismatch, "transaction %s is in %s, not %s", original.TransactionId, original.Amount.Currency, reversal.Amount.Currency)
+	}
+
+	var reversed int64
+	err := db.Raw("select coalesce(sum(amount_units), 0) from transactions where reversal_of = ? and state in ? and transaction_id <> ?",
+		original.TransactionId, states, reversal.TransactionId).Scan(&reversed).Error
+	if err != nil {
+		return nil, money.Money{}, fmt.Errorf("failed to sum reversals : %v", err)
+	}
+
+	remaining := money.New(original.Amount.Units-reversed, original.Amount.Currency)
+	if reversal.Amount.Units > remaining.Units {
+		return nil, money.Money{}, Reject(ReasonInvalidTransaction, "only %s of transaction %s is left to reverse", remaining, original.TransactionId)
+	}
+	return &original, remaining, nil
+}
+
+// SaveReversal saves reversal as PENDING unless, counting the reversals still pending, the
+// reversed transaction would be reversed beyond its amount.
+func (t *TransactionModel) SaveReversal(reversal *Transaction) error {
+	return t.DB.Transaction(func(dbTx *gorm.DB) error {
+		_, _, err := lockReversible(dbTx, reversal, TransactionPending, TransactionCompleted)
+		if err != nil {
+			return err
+		}
+		return t.SavePending(reversal, dbTx)
+	})
+}
+
+// CheckReversal checks again, in the worker's transaction, that the completed reversals leave
+// room for reversal. The reversal reversing what is left of a transaction charged a fee also
+// gives the fee back : reversal.FeeRefund is set and the postings of the refund are returned.
+func (t *TransactionModel) CheckReversal(reversal *Transaction, dbTx *gorm.DB) ([]Posting, error) {
+	original, remaining, err := lockReversible(dbTx, reversal, TransactionCompleted)
+	if err != nil {
+		return nil, err
+	}
+	if reversal.Amount.Units < remaining.Units || !original.Fee.IsPositive() {
+		return nil, nil
+	}
+
+	reversal.FeeRefund = original.Fee
+	return []Posting{
+		{AccountId: FeeIncomeAccountId, Amount: original.Fee.Neg()},
+		{AccountId: original.Sender, Amount: original.Fee},
+	}, nil
+}
+
+// Reversals returns the reversals of a transaction, oldest first.
+func (t *TransactionModel) Reversals(transactionId string) ([]Transaction, error) {
+	var reversals []Transaction
+	err := t.DB.Where("reversal_of = ?", transactionId).Order("created_time").Find(&reversals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reversals : %v", err)
+	}
+	return reversals, nil
+}
+
+// ReversedAmount sums the completed reversals of a transaction.
+func (t *TransactionModel) ReversedAmount(original *Transaction) (money.Money, error) {
+	var reversed int64
+	err := t.DB.Raw("select coalesce(sum(amount_units), 0) from transactions where reversal_of = ? and state = ?",
+		original.TransactionId, TransactionCompleted).Scan(&reversed).Error
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to sum reversals : %v", err)
+	}
+	return money.New(reversed, original.Amount.Currency), nil
+}
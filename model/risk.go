@@ -0,0 +1,287 @@
+package model
+
+import (
+	"account-management/money"
+	"account-management/risk"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TransactionUnderReview is the state of a transaction a risk rule sent to the case queue ; it
+// is processed once an analyst clears its case.
+const TransactionUnderReview = "UNDER_REVIEW"
+
+// States of a risk case, the last two being the decisions of the analyst.
+const (
+	RiskCaseOpen     = "OPEN"
+	RiskCaseCleared  = "CLEARED"
+	RiskCaseDeclined = "DECLINED"
+)
+
+var ErrRiskCaseNotFound = errors.New("risk case doesn't exist")
+
+// riskRules holds the rules of the risk stage, loaded from the configuration.
+var riskRules []risk.Rule
+
+func SetRiskRules(rules []risk.Rule) {
+	riskRules = rules
+}
+
+// riskScreenedTypes are the transactions the risk stage evaluates : the money customers send.
+var riskScreenedTypes = []string{"Withdraw", "Transfer"}
+
+// RiskCase is a transaction waiting for an analyst, sent by the rule RuleId.
+type RiskCase struct {
+	CaseId        string `gorm:"primaryKey"`
+	TransactionId string `gorm:"uniqueIndex"`
+	AccountId     string `gorm:"index"`
+	Type          string
+	Amount        money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	RuleId        string
+	Detail        string
+	State         string `gorm:"index"`
+	// AnalystId is the user who decided, with the Note given.
+	AnalystId   string
+	Note        string
+	CreatedTime time.Time
+	DecidedTime *time.Time
+}
+
+type RiskModel struct {
+	DB *gorm.DB
+}
+
+func NewRiskModel(db *gorm.DB) *RiskModel {
+	return &RiskModel{
+		DB: db,
+	}
+}
+
+// riskHistory answers the questions of the rules from the transactions of the sender.
+type riskHistory struct {
+	db            *gorm.DB
+	sender        string
+	transactionId string
+}
+
+func (h *riskHistory) Count(txType string, since time.Time) (int, error) {
+	var count int64
+	err := h.db.Raw("select count(*) from transactions where sender = ? and type = ? and created_time >= ? and transaction_id <> ? and state in ?",
+		h.sender, txType, since, h.transactionId, []string{TransactionPending, TransactionCompleted, TransactionAwaitingApproval, TransactionUnderReview}).
+		Scan(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count transactions : %v", err)
+	}
+	return int(count), nil
+}
+
+func (h *riskHistory) Total(txType string, since time.Time) (money.Money, int, error) {
+	var rows []struct {
+		Units    int64
+		Currency string
+		Count    int
+	}
+	err := h.db.Raw(`select coalesce(sum(amount_units), 0) as units, amount_currency as currency, count(*) as count from transactions
+		where sender = ? and type = ? and created_time >= ? and transaction_id <> ? and state = ?
+		group by amount_currency order by count desc`,
+		h.sender, txType, since, h.transactionId, TransactionCompleted).Scan(&rows).Error
+	if err != nil {
+		return money.Money{}, 0, fmt.Errorf("failed to sum transactions : %v", err)
+	}
+	if len(rows) == 0 {
+		return money.Money{}, 0, nil
+	}
+	return money.New(rows[0].Units, rows[0].Currency), rows[0].Count, nil
+}
+
+func (h *riskHistory) PaidBefore(receiver string) (bool, error) {
+	var count int64
+	err := h.db.Raw("select count(*) from transactions where sender = ? and receiver = ? and type = ? and transaction_id <> ? and state = ?",
+		h.sender, receiver, "Transfer", h.transactionId, TransactionCompleted).Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get transactions : %v", err)
+	}
+	return count > 0, nil
+}
+
+// Screen runs the risk rules on the pending transaction tx before the worker posts it. A
+// blocked transaction is REJECTED with the id of the rule, a reviewed one is put UNDER_REVIEW
+// with a case for the analysts ; the decision is returned. Hold captures are screened like the
+// withdrawals and transfers they post, and transactions cleared by an analyst are allowed.
+func (r *RiskModel) Screen(tx *Transaction, transactionModel *TransactionModel) (string, error) {
+	screened := false
+	for _, t := range riskScreenedTypes {
+		screened = screened || t == tx.Type
+	}
+	if !screened || len(riskRules) == 0 {
+		return risk.Allow, nil
+	}
+
+	decision := risk.Allow
+	err := r.DB.Transaction(func(dbTx *gorm.DB) error {
+		err := transactionModel.LockPending(tx, dbTx)
+		if err != nil {
+			return err
+		}
+
+		var cleared int64
+		err = dbTx.Raw("select count(*) from risk_cases where transaction_id = ? and state = ?", tx.TransactionId, RiskCaseCleared).Scan(&cleared).Error
+		if err != nil {
+			return fmt.Errorf("failed to get risk case : %v", err)
+		}
+		if cleared > 0 {
+			return nil
+		}
+
+		var created []time.Time
+		err = dbTx.Raw("select created_time from accounts where account_id = ?", tx.Sender).Scan(&created).Error
+		if err != nil {
+			return fmt.Errorf("failed to get account : %v", err)
+		}
+		if len(created) == 0 {
+			return Reject(ReasonAccountNotFound, "sender account doesn't exist")
+		}
+
+		subject := risk.Subject{
+			TransactionId:  tx.TransactionId,
+			Type:           tx.Type,
+			Sender:         tx.Sender,
+			Receiver:       tx.Receiver,
+			Amount:         tx.Amount,
+			AccountCreated: created[0],
+			Now:            time.Now(),
+		}
+		result, err := risk.Evaluate(riskRules, subject, &riskHistory{db: dbTx, sender: tx.Sender, transactionId: tx.TransactionId})
+		if err != nil {
+			return err
+		}
+		decision = result.Decision
+
+		now := time.Now()
+		switch result.Decision {
+		case risk.Block:
+			err = dbTx.Exec("update transactions set state = ?, reason = ?, detail = ?, risk_rule = ?, rejected_time = ? where transaction_id = ?",
+				TransactionRejected, ReasonRiskBlocked, result.Detail, result.RuleId, now, tx.TransactionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to reject transaction : %v", err)
+			}
+			tx.State, tx.Reason, tx.Detail, tx.RiskRule, tx.RejectedTime = TransactionRejected, ReasonRiskBlocked, result.Detail, result.RuleId, &now
+			err = emitTransactionRejected(dbTx, tx)
+			if err != nil {
+				return err
+			}
+
+		case risk.Review:
+			err = dbTx.Exec("update transactions set state = ?, detail = ?, risk_rule = ? where transaction_id = ?",
+				TransactionUnderReview, result.Detail, result.RuleId, tx.TransactionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to save transaction : %v", err)
+			}
+			err = dbTx.Create(&RiskCase{
+				CaseId:        uuid.NewString(),
+				TransactionId: tx.TransactionId,
+				AccountId:     tx.Sender,
+				Type:          tx.Type,
+				Amount:        tx.Amount,
+				RuleId:        result.RuleId,
+				Detail:        result.Detail,
+				State:         RiskCaseOpen,
+				CreatedTime:   now,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to save risk case : %v", err)
+			}
+			tx.State, tx.Detail, tx.RiskRule = TransactionUnderReview, result.Detail, result.RuleId
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return decision, nil
+}
+
+// Cases returns the cases in state, oldest first.
+func (r *RiskModel) Cases(state string) ([]RiskCase, error) {
+	var cases []RiskCase
+	err := r.DB.Where("state = ?", state).Order("created_time").Find(&cases).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk cases : %v", err)
+	}
+	return cases, nil
+}
+
+// Decide records the decision of the analyst analystId on an open case. Clearing it makes its
+// transaction PENDING again, ready is then set for the caller to queue it ; declining it rejects
+// the transaction.
+func (r *RiskModel) Decide(caseId, analystId, decision, note string) (tx *Transaction, ready bool, err error) {
+	if decision != RiskCaseCleared && decision != RiskCaseDeclined {
+		return nil, false, fmt.Errorf("decision must be %s or %s", RiskCaseCleared, RiskCaseDeclined)
+	}
+
+	err = r.DB.Transaction(func(dbTx *gorm.DB) error {
+		var riskCase RiskCase
+		result := dbTx.Raw("select * from risk_cases where case_id = ? for update", caseId).Scan(&riskCase)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to get risk case : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRiskCaseNotFound
+		}
+		if riskCase.State != RiskCaseOpen {
+			return fmt.Errorf("risk case is already %s", riskCase.State)
+		}
+
+		var current Transaction
+		result = dbTx.Raw("select * from transactions where transaction_id = ? for update", riskCase.TransactionId).Scan(&current)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to get transaction : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return ErrTransactionNotFound
+		}
+		if current.State != TransactionUnderReview {
+			return fmt.Errorf("transaction is %s, not under review", current.State)
+		}
+
+		now := time.Now()
+		err := dbTx.Exec("update risk_cases set state = ?, analyst_id = ?, note = ?, decided_time = ? where case_id = ?",
+			decision, analystId, note, now, caseId).Error
+		if err != nil {
+			return fmt.Errorf("failed to save risk case : %v", err)
+		}
+
+		if decision == RiskCaseDeclined {
+			current.State = TransactionRejected
+			current.Reason = ReasonRiskDeclined
+			current.Detail = note
+			current.RejectedTime = &now
+			err = dbTx.Exec("update transactions set state = ?, reason = ?, detail = ?, rejected_time = ? where transaction_id = ?",
+				current.State, current.Reason, current.Detail, now, current.TransactionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to reject transaction : %v", err)
+			}
+			err = emitTransactionRejected(dbTx, &current)
+			if err != nil {
+				return err
+			}
+		} else {
+			current.State = TransactionPending
+			err = dbTx.Exec("update transactions set state = ? where transaction_id = ?", current.State, current.TransactionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to clear transaction : %v", err)
+			}
+			ready = true
+		}
+		tx = &current
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return tx, ready, nil
+}
@@ -0,0 +1,45 @@
+package model
+
+const (
+	RoleCustomer = "customer"
+	RoleSupport  = "support"
+	RoleAdmin    = "admin"
+	RoleAuditor  = "auditor"
+	RoleAnalyst  = "analyst"
+)
+
+// Permissions granted by roles. Customers have none : they only ever reach their own data.
+const (
+	PermissionReadAccounts        = "accounts:read"
+	PermissionReadTransactions    = "transactions:read"
+	PermissionWriteAccountState   = "accounts:write-state"
+	PermissionWriteRoles          = "roles:write"
+	PermissionWriteLimits         = "accounts:write-limits"
+	PermissionWriteProducts       = "accounts:write-product"
+	PermissionReverseTransactions = "transactions:reverse"
+	PermissionReadRiskCases       = "risk:read"
+	PermissionReviewRiskCases     = "risk:review"
+	PermissionReadAuditLog        = "audit:read"
+)
+
+var rolePermissions = map[string][]string{
+	RoleCustomer: {},
+	RoleSupport:  {PermissionReadAccounts, PermissionReadTransactions},
+	RoleAuditor:  {PermissionReadAccounts, PermissionReadTransactions, PermissionReadRiskCases, PermissionReadAuditLog},
+	RoleAnalyst:  {PermissionReadAccounts, PermissionReadTransactions, PermissionReadRiskCases, PermissionReviewRiskCases},
+	RoleAdmin:    {PermissionReadAccounts, PermissionReadTransactions, PermissionWriteAccountState, PermissionWriteRoles, PermissionWriteLimits, PermissionWriteProducts, PermissionReverseTransactions, PermissionReadRiskCases, PermissionReviewRiskCases, PermissionReadAuditLog},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
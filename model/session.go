@@ -0,0 +1,154 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid, expired or revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, every session of this login has been revoked")
+)
+
+// Session is one refresh token. Each refresh rotates it : the old row is marked rotated and a new
+// row of the same family (all the tokens descending from one login) is created. Presenting a
+// rotated token again means it leaked, so the whole family is revoked.
+type Session struct {
+	SessionId        string `gorm:"primaryKey"`
+	FamilyId         string `gorm:"index"`
+	UserId           string `gorm:"index"`
+	RefreshTokenHash string `gorm:"uniqueIndex"`
+	CreatedTime      time.Time
+	ExpiresTime      time.Time
+	RotatedTime      *time.Time
+	RevokedTime      *time.Time
+}
+
+type SessionModel struct {
+	DB *gorm.DB
+}
+
+func NewSessionModel(db *gorm.DB) *SessionModel {
+	return &SessionModel{
+		DB: db,
+	}
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token : %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create starts a session in familyId (a new family when blank) and returns it with its refresh
+// token ; only the hash of the token is stored.
+func (s *SessionModel) Create(userId, familyId string, lifespan time.Duration, txs ...*gorm.DB) (*Session, string, error) {
+	var db = s.DB
+
+	if len(txs) > 0 {
+		db = txs[0]
+	}
+
+	token, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if familyId == "" {
+		familyId = uuid.NewString()
+	}
+
+	now := time.Now()
+	session := &Session{
+		SessionId:        uuid.NewString(),
+		FamilyId:         familyId,
+		UserId:           userId,
+		RefreshTokenHash: hashRefreshToken(token),
+		CreatedTime:      now,
+		ExpiresTime:      now.Add(lifespan),
+	}
+
+	err = db.Create(session).Error
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save session : %v", err)
+	}
+	return session, token, nil
+}
+
+// Rotate exchanges a refresh token for a new one of the same family. On reuse of an already
+// rotated token the family is revoked and the returned session tells which family it was.
+func (s *SessionModel) Rotate(refreshToken string, lifespan time.Duration) (*Session, string, error) {
+	var (
+		next     *Session
+		newToken string
+		reused   *Session
+	)
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var current Session
+		result := tx.Raw("select * from sessions where refresh_token_hash = ? for update", hashRefreshToken(refreshToken)).Scan(&current)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to get session : %v", err)
+		}
+		if result.RowsAffected == 0 || current.RevokedTime != nil || time.Now().After(current.ExpiresTime) {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.RotatedTime != nil {
+			reused = &current
+			return nil
+		}
+
+		now := time.Now()
+		err := tx.Exec("update sessions set rotated_time = ? where session_id = ?", now, current.SessionId).Error
+		if err != nil {
+			return fmt.Errorf("failed to rotate session : %v", err)
+		}
+
+		next, newToken, err = s.Create(current.UserId, current.FamilyId, lifespan, tx)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if reused != nil {
+		if err := s.RevokeFamily(reused.FamilyId); err != nil {
+			return nil, "", err
+		}
+		return reused, "", ErrRefreshTokenReused
+	}
+
+	return next, newToken, nil
+}
+
+func (s *SessionModel) RevokeFamily(familyId string) error {
+	err := s.DB.Exec("update sessions set revoked_time = ? where family_id = ? and revoked_time is null", time.Now(), familyId).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions : %v", err)
+	}
+	return nil
+}
+
+func (s *SessionModel) RevokeUser(userId string) error {
+	err := s.DB.Exec("update sessions set revoked_time = ? where user_id = ? and revoked_time is null", time.Now(), userId).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions : %v", err)
+	}
+	return nil
+}
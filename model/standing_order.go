@@ -0,0 +1,295 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Schedules of standing orders.
+const (
+	ScheduleOnce            = "ONCE"              // once, on StartDate
+	ScheduleWeekly          = "WEEKLY"            // every week on the weekday of StartDate
+	ScheduleMonthly         = "MONTHLY"           // every month on DayOfMonth, or the last day of shorter months
+	ScheduleLastBusinessDay = "LAST_BUSINESS_DAY" // every month on its last weekday
+)
+
+const (
+	StandingOrderActive    = "ACTIVE"
+	StandingOrderCancelled = "CANCELLED"
+	StandingOrderFinished  = "FINISHED"
+)
+
+// States of an execution which didn't produce a transaction, or whose transaction was queued.
+const (
+	ExecutionSubmitted = "SUBMITTED"
+	ExecutionRejected  = "REJECTED"
+)
+
+var ErrStandingOrderNotFound = errors.New("standing order doesn't exist")
+
+// StandingOrder transfers Amount from AccountId to Receiver on every date of its schedule, from
+// StartDate until EndDate (inclusive) when set. Dates are days in UTC.
+type StandingOrder struct {
+	StandingOrderId string `gorm:"primaryKey"`
+	AccountId       string `gorm:"index"`
+	Receiver        string
+	Amount          money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	Schedule        string
+	DayOfMonth      int
+	StartDate       time.Time
+	EndDate         *time.Time
+	// NextRunDate is the date of the next execution, nil once the order is finished or cancelled.
+	NextRunDate *time.Time `gorm:"index"`
+	State       string
+	Description string
+	// CreatedBy is the user who created the order ; its transfers count as approved by them.
+	CreatedBy     string
+	CreatedTime   time.Time
+	CancelledTime *time.Time
+}
+
+// StandingOrderExecution records one run of a standing order and the transaction it queued. A
+// run rejected before reaching the queue (e.g. a frozen account) has no transaction.
+type StandingOrderExecution struct {
+	ExecutionId     int64     `gorm:"primaryKey;autoIncrement"`
+	StandingOrderId string    `gorm:"uniqueIndex:idx_standing_order_executions_run"`
+	RunDate         time.Time `gorm:"uniqueIndex:idx_standing_order_executions_run"`
+	TransactionId   string
+	State           string
+	Reason          string
+	Detail          string
+	CreatedTime     time.Time
+}
+
+type StandingOrderModel struct {
+	DB *gorm.DB
+}
+
+func NewStandingOrderModel(db *gorm.DB) *StandingOrderModel {
+	return &StandingOrderModel{
+		DB: db,
+	}
+}
+
+// Day truncates t to its day in UTC.
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func lastDayOfMonth(year int, month time.Month) time.Time {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+}
+
+// dateInMonth is the date of a monthly schedule in the given month.
+func (o *StandingOrder) dateInMonth(year int, month time.Month) time.Time {
+	last := lastDayOfMonth(year, month)
+	if o.Schedule == ScheduleLastBusinessDay {
+		for last.Weekday() == time.Saturday || last.Weekday() == time.Sunday {
+			last = last.AddDate(0, 0, -1)
+		}
+		return last
+	}
+
+	if o.DayOfMonth < last.Day() {
+		return time.Date(year, month, o.DayOfMonth, 0, 0, 0, 0, time.UTC)
+	}
+	return last
+}
+
+func (o *StandingOrder) withinEnd(date time.Time) (*time.Time, bool) {
+	if o.EndDate != nil && date.After(*o.EndDate) {
+		return nil, false
+	}
+	return &date, true
+}
+
+// firstRun is the first date of the schedule on or after StartDate.
+func (o *StandingOrder) firstRun() (*time.Time, bool) {
+	start := Day(o.StartDate)
+
+	switch o.Schedule {
+	case ScheduleOnce, ScheduleWeekly:
+		return o.withinEnd(start)
+	}
+
+	date := o.dateInMonth(start.Year(), start.Month())
+	if date.Before(start) {
+		date = o.dateInMonth(start.Year(), start.Month()+1)
+	}
+	return o.withinEnd(date)
+}
+
+// nextRun is the date of the schedule following the run of date, false when there is none.
+func (o *StandingOrder) nextRun(date time.Time) (*time.Time, bool) {
+	switch o.Schedule {
+	case ScheduleOnce:
+		return nil, false
+	case ScheduleWeekly:
+		return o.withinEnd(date.AddDate(0, 0, 7))
+	}
+	return o.withinEnd(o.dateInMonth(date.Year(), date.Month()+1))
+}
+
+func (o *StandingOrder) validate() error {
+	switch o.Schedule {
+	case ScheduleOnce, ScheduleWeekly, ScheduleLastBusinessDay:
+	case ScheduleMonthly:
+		if o.DayOfMonth < 1 || o.DayOfMonth > 31 {
+			return errors.New("day_of_month must be between 1 and 31")
+		}
+	default:
+		return fmt.Errorf("schedule must be one of %s, %s, %s, %s", ScheduleOnce, ScheduleWeekly, ScheduleMonthly, ScheduleLastBusinessDay)
+	}
+
+	if o.EndDate != nil && o.EndDate.Before(o.StartDate) {
+		return errors.New("end_date must not be before start_date")
+	}
+	return nil
+}
+
+// Create validates order and saves it ACTIVE with the first date of its schedule.
+func (s *StandingOrderModel) Create(order *StandingOrder) error {
+	order.Schedule = strings.ToUpper(order.Schedule)
+	order.StartDate = Day(order.StartDate)
+	if order.EndDate != nil {
+		end := Day(*order.EndDate)
+		order.EndDate = &end
+	}
+
+	if err := order.validate(); err != nil {
+		return err
+	}
+
+	next, ok := order.firstRun()
+	if !ok {
+		return errors.New("schedule has no date between start_date and end_date")
+	}
+	if next.Before(Day(time.Now())) {
+		return errors.New("start_date must not be in the past")
+	}
+
+	order.StandingOrderId = uuid.NewString()
+	order.NextRunDate = next
+	order.State = StandingOrderActive
+	order.CreatedTime = time.Now()
+
+	err := s.DB.Create(order).Error
+	if err != nil {
+		return fmt.Errorf("failed to save standing order : %v", err)
+	}
+	return nil
+}
+
+func (s *StandingOrderModel) Get(standingOrderId string) (*StandingOrder, error) {
+	var order StandingOrder
+	result := s.DB.Where("standing_order_id = ?", standingOrderId).Limit(1).Find(&order)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get standing order : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrStandingOrderNotFound
+	}
+	return &order, nil
+}
+
+func (s *StandingOrderModel) ListByAccount(accountId string) ([]StandingOrder, error) {
+	var orders []StandingOrder
+	err := s.DB.Where("account_id = ?", accountId).Order("created_time desc").Find(&orders).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get standing orders : %v", err)
+	}
+	return orders, nil
+}
+
+// Cancel stops an active standing order of accountId ; executions already queued still run.
+func (s *StandingOrderModel) Cancel(standingOrderId, accountId string) error {
+	result := s.DB.Exec("update standing_orders set state = ?, next_run_date = null, cancelled_time = ? where standing_order_id = ? and account_id = ? and state = ?",
+		StandingOrderCancelled, time.Now(), standingOrderId, accountId, StandingOrderActive)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to cancel standing order : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("standing order doesn't exist or isn't active")
+	}
+	return nil
+}
+
+// Due returns the ids of the active orders whose next run is on or before today.
+func (s *StandingOrderModel) Due(today time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := s.DB.Raw("select standing_order_id from standing_orders where state = ? and next_run_date <= ? order by next_run_date limit ?",
+		StandingOrderActive, Day(today), limit).Scan(&ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due standing orders : %v", err)
+	}
+	return ids, nil
+}
+
+// LockDue locks a due order for the rest of dbTx. It returns nil when the order is no longer
+// due, e.g. because another scheduler just ran it.
+func (s *StandingOrderModel) LockDue(standingOrderId string, today time.Time, dbTx *gorm.DB) (*StandingOrder, error) {
+	var order StandingOrder
+	result := dbTx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("standing_order_id = ? and state = ? and next_run_date <= ?", standingOrderId, StandingOrderActive, Day(today)).
+		Limit(1).Find(&order)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to lock standing order : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+// RecordExecution saves the execution of the current run of order and moves the order to its
+// next run, or finishes it.
+func (s *StandingOrderModel) RecordExecution(order *StandingOrder, execution *StandingOrderExecution, dbTx *gorm.DB) error {
+	execution.StandingOrderId = order.StandingOrderId
+	execution.RunDate = *order.NextRunDate
+	execution.CreatedTime = time.Now()
+
+	err := dbTx.Clauses(clause.OnConflict{DoNothing: true}).Create(execution).Error
+	if err != nil {
+		return fmt.Errorf("failed to save standing order execution : %v", err)
+	}
+
+	next, ok := order.nextRun(*order.NextRunDate)
+	state := StandingOrderActive
+	if !ok {
+		state = StandingOrderFinished
+	}
+
+	err = dbTx.Exec("update standing_orders set next_run_date = ?, state = ? where standing_order_id = ?", next, state, order.StandingOrderId).Error
+	if err != nil {
+		return fmt.Errorf("failed to schedule standing order : %v", err)
+	}
+
+	order.NextRunDate = next
+	order.State = state
+	return nil
+}
+
+// Executions returns the runs of an order, newest first, with the state of their transaction.
+func (s *StandingOrderModel) Executions(standingOrderId string) ([]StandingOrderExecution, error) {
+	var executions []StandingOrderExecution
+	err := s.DB.Raw(`select e.execution_id, e.standing_order_id, e.run_date, e.transaction_id, e.created_time,
+			coalesce(t.state, e.state) as state,
+			coalesce(nullif(t.reason, ''), e.reason) as reason,
+			coalesce(nullif(t.detail, ''), e.detail) as detail
+		from standing_order_executions e
+		left join transactions t on t.transaction_id = e.transaction_id
+		where e.standing_order_id = ?
+		order by e.run_date desc`, standingOrderId).Scan(&executions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get standing order executions : %v", err)
+	}
+	return executions, nil
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Statement lists the postings of an account over [From, To) between its opening and closing
+// balances. Balances come from the ledger, so they add up whatever the projection says.
+type Statement struct {
+	AccountId      string
+	Username       string
+	Currency       string
+	From           time.Time
+	To             time.Time
+	CreatedTime    time.Time
+	OpeningBalance money.Money
+	ClosingBalance money.Money
+	Lines          []StatementLine
+}
+
+// StatementLine is one posting of the account. Amount is signed : negative for a debit.
+type StatementLine struct {
+	EntryId       string
+	TransactionId string
+	Type          string
+	Kind          string // kind of the journal entry, EntryKindFee for fees
+	Description   string
+	Counterparty  string
+	BookedTime    time.Time
+	Amount        money.Money
+	Balance       money.Money
+}
+
+func (l StatementLine) IsDebit() bool {
+	return l.Amount.IsNegative()
+}
+
+type statementRow struct {
+	EntryId       string
+	TransactionId string
+	Type          string
+	Kind          string
+	Description   string
+	Sender        string
+	Receiver      string
+	CreatedTime   time.Time
+	AmountUnits   int64
+}
+
+// Statement builds the statement of accountId for the period [from, to).
+func (l *LedgerModel) Statement(accountId string, from, to time.Time) (*Statement, error) {
+	if !to.After(from) {
+		return nil, errors.New("end of the period must be after its start")
+	}
+
+	account, err := l.AccountModel.GetAccount(accountId)
+	if err != nil {
+		return nil, err
+	}
+	currency := account.Balance.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	var username string
+	err = l.DB.Raw("select username from users where user_id = ?", account.UserId).Scan(&username).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account holder : %v", err)
+	}
+
+	var opening int64
+	err = l.DB.Raw("select coalesce(sum(amount_units), 0) from postings where account_id = ? and created_time < ?", accountId, from).
+		Scan(&opening).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get opening balance : %v", err)
+	}
+
+	var rows []statementRow
+	err = l.DB.Raw(`select p.entry_id, j.transaction_id, coalesce(t.type, '') as type, j.kind, j.description,
+			coalesce(t.sender, '') as sender, coalesce(t.receiver, '') as receiver, p.created_time, p.amount_units
+		from postings p
+		join journal_entries j on j.entry_id = p.entry_id
+		left join transactions t on t.transaction_id = j.transaction_id and j.transaction_id <> ''
+		where p.account_id = ? and p.created_time >= ? and p.created_time < ?
+		order by p.created_time, p.posting_id`, accountId, from, to).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement entries : %v", err)
+	}
+
+	statement := &Statement{
+		AccountId:      accountId,
+		Username:       username,
+		Currency:       currency,
+		From:           from,
+		To:             to,
+		CreatedTime:    time.Now(),
+		OpeningBalance: money.New(opening, currency),
+		Lines:          make([]StatementLine, 0, len(rows)),
+	}
+
+	balance := opening
+	for _, row := range rows {
+		balance += row.AmountUnits
+
+		counterparty := row.Receiver
+		if row.Receiver == accountId {
+			counterparty = row.Sender
+		}
+		if row.Type != "Transfer" {
+			counterparty = ""
+		}
+
+		statement.Lines = append(statement.Lines, StatementLine{
+			EntryId:       row.EntryId,
+			TransactionId: row.TransactionId,
+			Type:          row.Type,
+			Kind:          row.Kind,
+			Description:   row.Description,
+			Counterparty:  counterparty,
+			BookedTime:    row.CreatedTime,
+			Amount:        money.New(row.AmountUnits, currency),
+			Balance:       money.New(balance, currency),
+		})
+	}
+	statement.ClosingBalance = money.New(balance, currency)
+
+	return statement, nil
+}
@@ -1,19 +1,60 @@
 package model
 
 import (
+	"account-management/money"
+	"errors"
 	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	TransactionPending   = "PENDING"
+	TransactionCompleted = "COMPLETED"
+	TransactionRejected  = "REJECTED"
+	TransactionFailed    = "FAILED"
+)
+
+var (
+	ErrTransactionNotFound   = errors.New("transaction doesn't exist")
+	ErrTransactionNotPending = errors.New("transaction was already processed")
+)
+
 type Transaction struct {
 	TransactionId string `gorm:"primaryKey"`
 	Sender        string
 	Receiver      string
-	Amount        float64
-	CreatedTime   time.Time
-	Type          string
+	Amount        money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	// Fee is charged to Sender on top of Amount, it is known once the transaction is processed.
+	Fee money.Money `gorm:"embedded;embeddedPrefix:fee_"`
+	// FeeRefund is the fee of the reversed transaction a Reversal gave back, see CheckReversal.
+	FeeRefund   money.Money `gorm:"embedded;embeddedPrefix:fee_refund_"`
+	CreatedTime time.Time
+	Type        string
+	// IdempotencyKey is the client's Idempotency-Key ; at most one transaction per sender carries a given key.
+	IdempotencyKey string
+	// HoldId is the hold this transaction captures, if any.
+	HoldId string
+	// ReversalOf is the transaction a Reversal compensates. Forced lets a reversal take its sender
+	// below the floor when the funds were already spent.
+	ReversalOf string `gorm:"index"`
+	Forced     bool
+	// RequestId is the id of the api request which created the transaction, if any.
+	RequestId string
+	// Description is set by whoever created the transaction, e.g. the reason of a reversal.
+	Description string
+	// ApprovalsRequired is the number of holders who had to approve the transaction, 0 or 1 when
+	// its initiator was enough.
+	ApprovalsRequired int
+	// RiskRule is the risk rule which blocked the transaction or sent it for review.
+	RiskRule      string
+	State         string `gorm:"index"`
+	Reason        string
+	Detail        string
+	CompletedTime *time.Time
+	RejectedTime  *time.Time
+	FailedTime    *time.Time
 }
 
 type TransactionModel struct {
@@ -28,24 +69,159 @@ func NewTransactionModel(db *gorm.DB) *TransactionModel {
 	}
 }
 
-func (t *TransactionModel) Save(tx *Transaction) error {
+func (t *TransactionModel) Save(tx *Transaction, txs ...*gorm.DB) error {
+	var db = t.DB
+
+	if len(txs) > 0 {
+		db = txs[0]
+	}
+
 	tx.CreatedTime = time.Now()
-	err := t.DB.Create(tx).Error
+	err := db.Create(tx).Error
 	if err != nil {
 		return fmt.Errorf("failed to save transaction : %v", err)
 	}
 	return nil
 }
 
-func (t *TransactionModel) GetTransactionState(transactionId string) bool {
-	var transactionExist string
-	err := t.DB.Raw("select transaction_id from transactions where transaction_id = ?", transactionId).Scan(&transactionExist).Error
+// SavePending persists tx as PENDING before it is handed to the task queue.
+func (t *TransactionModel) SavePending(tx *Transaction, txs ...*gorm.DB) error {
+	tx.State = TransactionPending
+	tx.Reason = ""
+	tx.Detail = ""
+	tx.CompletedTime = nil
+	tx.RejectedTime = nil
+	tx.FailedTime = nil
+	return t.Save(tx, txs...)
+}
+
+// LockPending locks the row of tx for the rest of dbTx and checks it is still PENDING, so a
+// message delivered twice (or to two workers) is posted only once.
+func (t *TransactionModel) LockPending(tx *Transaction, dbTx *gorm.DB) error {
+	var state string
+	err := dbTx.Raw("select state from transactions where transaction_id = ? for update", tx.TransactionId).Scan(&state).Error
+	if err != nil {
+		return fmt.Errorf("failed to lock transaction : %v", err)
+	}
+	if state == "" {
+		return ErrTransactionNotFound
+	}
+	if state != TransactionPending {
+		return ErrTransactionNotPending
+	}
+	return nil
+}
+
+// Complete moves a PENDING transaction to COMPLETED. It fails when the transaction is missing or
+// has already left PENDING, which also keeps a redelivered message from being posted twice.
+func (t *TransactionModel) Complete(tx *Transaction, txs ...*gorm.DB) error {
+	var db = t.DB
+
+	if len(txs) > 0 {
+		db = txs[0]
+	}
+
+	now := time.Now()
+	result := db.Exec(`update transactions set state = ?, completed_time = ?, fee_units = ?, fee_currency = ?, fee_refund_units = ?, fee_refund_currency = ?
+		where transaction_id = ? and state = ?`,
+		TransactionCompleted, now, tx.Fee.Units, tx.Fee.Currency, tx.FeeRefund.Units, tx.FeeRefund.Currency, tx.TransactionId, TransactionPending)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to complete transaction : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to complete transaction : %s is not pending", tx.TransactionId)
+	}
+
+	tx.State = TransactionCompleted
+	tx.CompletedTime = &now
+	return emitTransactionCompleted(db, tx)
+}
+
+func (t *TransactionModel) Reject(tx *Transaction, reason, detail string) error {
+	now := time.Now()
+	return t.DB.Transaction(func(db *gorm.DB) error {
+		result := db.Exec("update transactions set state = ?, reason = ?, detail = ?, rejected_time = ? where transaction_id = ? and state = ?",
+			TransactionRejected, reason, detail, now, tx.TransactionId, TransactionPending)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to reject transaction : %v", err)
+		}
+
+		tx.State = TransactionRejected
+		tx.Reason = reason
+		tx.Detail = detail
+		tx.RejectedTime = &now
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return emitTransactionRejected(db, tx)
+	})
+}
+
+// Fail moves a PENDING transaction to FAILED ; ErrTransactionNotPending when it already left
+// PENDING, tx is then left as it was.
+func (t *TransactionModel) Fail(tx *Transaction, reason, detail string) error {
+	now := time.Now()
+	result := t.DB.Exec("update transactions set state = ?, reason = ?, detail = ?, failed_time = ? where transaction_id = ? and state = ?",
+		TransactionFailed, reason, detail, now, tx.TransactionId, TransactionPending)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to mark transaction as failed : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTransactionNotPending
+	}
+
+	tx.State = TransactionFailed
+	tx.Reason = reason
+	tx.Detail = detail
+	tx.FailedTime = &now
+	return nil
+}
+
+// Finish records the outcome of processing tx : a RejectionError rejects it with its reason, a
+// transient error (see IsTransientError) leaves it PENDING to be processed again, any other error
+// marks it FAILED.
+func (t *TransactionModel) Finish(tx *Transaction, processErr error) error {
+	var rejection *RejectionError
+	if errors.As(processErr, &rejection) {
+		return t.Reject(tx, rejection.Reason, rejection.Message)
+	}
+	if IsTransientError(processErr) {
+		return nil
+	}
+	return t.Fail(tx, ReasonProcessingError, processErr.Error())
+}
+
+// BackfillStates marks transactions written before lifecycle states existed as COMPLETED,
+// since a row used to be saved only once its postings succeeded.
+func (t *TransactionModel) BackfillStates() error {
+	err := t.DB.Exec("update transactions set state = ?, completed_time = created_time where state is null or state = ''", TransactionCompleted).Error
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to backfill transaction states : %v", err)
 	}
+	return nil
+}
 
-	if transactionExist != "" {
-		return true
+func (t *TransactionModel) GetTransaction(transactionId string) (*Transaction, error) {
+	var tx Transaction
+	result := t.DB.Where("transaction_id = ?", transactionId).Limit(1).Find(&tx)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get transaction : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTransactionNotFound
+	}
+	return &tx, nil
+}
+
+// GetByIdempotencyKey returns the transaction sender created with key, nil when there is none.
+func (t *TransactionModel) GetByIdempotencyKey(sender, key string) (*Transaction, error) {
+	var tx Transaction
+	result := t.DB.Where("sender = ? and idempotency_key = ?", sender, key).Limit(1).Find(&tx)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get transaction : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
 	}
-	return false
+	return &tx, nil
 }
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrUserNotFound = errors.New("user doesn't exist")
+
+// User is a login : a customer or a member of staff. A user owns one or more accounts, the
+// DefaultAccountId one is used when a request doesn't name an account.
+type User struct {
+	UserId   string `gorm:"primaryKey"`
+	Username string `gorm:"unique"`
+	Password string
+	// Name is the full name of the user, which payers confirm before paying its accounts.
+	Name             string
+	Role             string `gorm:"default:customer"`
+	DefaultAccountId string
+	CreatedTime      time.Time
+}
+
+type UserModel struct {
+	DB *gorm.DB
+}
+
+func NewUserModel(db *gorm.DB) *UserModel {
+	return &UserModel{
+		DB: db,
+	}
+}
+
+// Register creates user with its default account, of the default product, funded with the
+// initial balance.
+func (u *UserModel) Register(user *User) (*Account, error) {
+	if u.UserNameExist(user.Username) {
+		return nil, errors.New("username existed")
+	}
+
+	user.UserId = uuid.NewString()
+	user.Role = RoleCustomer
+	user.CreatedTime = time.Now()
+
+	account := &Account{
+		UserId:  user.UserId,
+		Product: DefaultProduct,
+	}
+
+	err := u.DB.Transaction(func(tx *gorm.DB) error {
+		err := openAccount(tx, account, initBalance)
+		if err != nil {
+			return err
+		}
+
+		user.DefaultAccountId = account.AccountId
+		err = tx.Create(user).Error
+		if err != nil {
+			return fmt.Errorf("failed to save user : %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (u *UserModel) UserNameExist(username string) bool {
+	var usernameExist string
+	err := u.DB.Raw("select username from users where username = ?", username).Scan(&usernameExist).Error
+	if err != nil {
+		return false
+	}
+
+	if usernameExist != "" {
+		return true
+	}
+	return false
+}
+
+func (u *UserModel) GetHashedPasswordByUsername(username string) string {
+	var hashedPassword string
+	err := u.DB.Raw("select password from users where username = ?", username).Scan(&hashedPassword).Error
+	if err != nil {
+		return ""
+	}
+	return hashedPassword
+}
+
+func (u *UserModel) GetUserIdByUserName(username string) (string, error) {
+	var userId string
+	err := u.DB.Raw("select user_id from users where username = ?", username).Scan(&userId).Error
+	if err != nil {
+		return "", err
+	}
+	return userId, nil
+}
+
+func (u *UserModel) GetUser(userId string) (*User, error) {
+	var user User
+	result := u.DB.Omit("password").Where("user_id = ?", userId).Limit(1).Find(&user)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get user : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (u *UserModel) GetUserRole(userId string) (string, error) {
+	var role string
+	err := u.DB.Raw("select role from users where user_id = ?", userId).Scan(&role).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get role's user : %v", err)
+	}
+	if role == "" {
+		role = RoleCustomer
+	}
+	return role, nil
+}
+
+func (u *UserModel) SetUserRole(userId string, role string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("unknown role : %s", role)
+	}
+
+	result := u.DB.Exec("update users set role = ? where user_id = ?", role, userId)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to set role's user : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (u *UserModel) GetDefaultAccountId(userId string) (string, error) {
+	var accountId string
+	err := u.DB.Raw("select default_account_id from users where user_id = ?", userId).Scan(&accountId).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get default account : %v", err)
+	}
+	if accountId == "" {
+		return "", errors.New("default account doesn't exist")
+	}
+	return accountId, nil
+}
+
+// SetDefaultAccount makes accountId, which userId must operate, the default account of userId.
+func (u *UserModel) SetDefaultAccount(userId, accountId string) error {
+	result := u.DB.Exec("update users set default_account_id = ? where user_id = ? and exists (select 1 from account_holders where account_id = ? and user_id = ? and role <> ?)",
+		accountId, userId, accountId, userId, HolderViewer)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed to set default account : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("account doesn't exist")
+	}
+	return nil
+}
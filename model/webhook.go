@@ -0,0 +1,512 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Events a webhook subscription can receive.
+const (
+	EventTransactionCompleted = "transaction.completed"
+	EventTransactionRejected  = "transaction.rejected"
+	EventAccountFrozen        = "account.frozen"
+)
+
+var webhookEvents = []string{EventTransactionCompleted, EventTransactionRejected, EventAccountFrozen}
+
+// States of a subscription : a DISABLED one receives nothing until its owner enables it again.
+const (
+	WebhookActive   = "ACTIVE"
+	WebhookDisabled = "DISABLED"
+)
+
+// States of a delivery : PENDING until the endpoint answers 2xx (SUCCEEDED) or every attempt
+// failed (FAILED).
+const (
+	DeliveryPending   = "PENDING"
+	DeliverySucceeded = "SUCCEEDED"
+	DeliveryFailed    = "FAILED"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook doesn't exist")
+	ErrDeliveryNotFound = errors.New("delivery doesn't exist")
+)
+
+// WebhookPolicy tells the dispatcher how to retry : attempt n waits RetryBase * 2^(n-1), at most
+// RetryMax, a delivery fails after MaxAttempts, and a subscription is disabled after
+// DisableAfter failed attempts in a row.
+type WebhookPolicy struct {
+	MaxAttempts  int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	DisableAfter int
+}
+
+var webhookPolicy = WebhookPolicy{MaxAttempts: 8, RetryBase: 30 * time.Second, RetryMax: 6 * time.Hour, DisableAfter: 20}
+
+func SetWebhookPolicy(policy WebhookPolicy) {
+	webhookPolicy = policy
+}
+
+// RetryDelay returns the wait after the failed attempt number attempts.
+func (p WebhookPolicy) RetryDelay(attempts int) time.Duration {
+	delay := p.RetryBase
+	for i := 1; i < attempts && delay < p.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > p.RetryMax {
+		delay = p.RetryMax
+	}
+	return delay
+}
+
+// WebhookSubscription sends the Events of the accounts UserId holds, or of AccountId only, to
+// Url. Payloads are signed with Secret, given to the user once, at creation.
+type WebhookSubscription struct {
+	SubscriptionId string `gorm:"primaryKey"`
+	UserId         string `gorm:"index"`
+	AccountId      string
+	Url            string
+	// Events is a comma separated list of events.
+	Events string
+	Secret string
+	State  string
+	// ConsecutiveFailures counts the failed attempts since the last success.
+	ConsecutiveFailures int
+	DisabledReason      string
+	CreatedTime         time.Time
+	DisabledTime        *time.Time
+}
+
+// WebhookDelivery is one event to send to one subscription. EventId is shared by the deliveries
+// of an event, and by its redeliveries, so that receivers can ignore duplicates.
+type WebhookDelivery struct {
+	DeliveryId     string `gorm:"primaryKey"`
+	SubscriptionId string `gorm:"index"`
+	EventId        string
+	EventType      string
+	Payload        string
+	State          string
+	Attempts       int
+	// NextAttemptTime is when the dispatcher sends the delivery again, while PENDING.
+	NextAttemptTime time.Time `gorm:"index"`
+	LastStatusCode  int
+	LastError       string
+	// RedeliveryOf is the delivery this one was manually sent again from.
+	RedeliveryOf  string
+	CreatedTime   time.Time
+	DeliveredTime *time.Time
+}
+
+// WebhookAttempt is an attempt of a delivery, for the delivery log.
+type WebhookAttempt struct {
+	AttemptId  int64  `gorm:"primaryKey;autoIncrement"`
+	DeliveryId string `gorm:"index"`
+	StatusCode int
+	Error      string
+	DurationMs int64
+	Time       time.Time
+}
+
+// webhookPayload is the body posted to the endpoints.
+type webhookPayload struct {
+	Id      string      `json:"id"`
+	Type    string      `json:"type"`
+	Created time.Time   `json:"created"`
+	Data    interface{} `json:"data"`
+}
+
+type WebhookModel struct {
+	DB *gorm.DB
+}
+
+func NewWebhookModel(db *gorm.DB) *WebhookModel {
+	return &WebhookModel{
+		DB: db,
+	}
+}
+
+// nonPublicNetworks are the ranges IsPublicIP refuses besides the private, loopback, link-local
+// and multicast ones known to package net.
+var nonPublicNetworks = []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4", "64:ff9b::/96"}
+
+// IsPublicIP tells whether webhooks may be sent to ip : deliveries must not reach the hosts
+// of the internal network, nor the metadata service of the cloud provider (169.254.169.254).
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, cidr := range nonPublicNetworks {
+		_, network, _ := net.ParseCIDR(cidr)
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscribe validates subscription and saves it ACTIVE with a new secret.
+func (w *WebhookModel) Subscribe(subscription *WebhookSubscription) error {
+	endpoint, err := url.Parse(subscription.Url)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Hostname() == "" {
+		return errors.New("url must be an absolute https url")
+	}
+	// the dispatcher checks the address again when it connects, the name may resolve elsewhere by then
+	ips, err := net.LookupIP(endpoint.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("host %s can't be resolved", endpoint.Hostname())
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("host %s isn't a public address", endpoint.Hostname())
+		}
+	}
+
+	events := strings.Split(subscription.Events, ",")
+	for i, event := range events {
+		events[i] = strings.TrimSpace(event)
+		valid := false
+		for _, e := range webhookEvents {
+			valid = valid || e == events[i]
+		}
+		if !valid {
+			return fmt.Errorf("events must be among %s", strings.Join(webhookEvents, ", "))
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate webhook secret : %v", err)
+	}
+
+	subscription.SubscriptionId = uuid.NewString()
+	subscription.Events = strings.Join(events, ",")
+	subscription.Secret = "whsec_" + hex.EncodeToString(secret)
+	subscription.State = WebhookActive
+	subscription.CreatedTime = time.Now()
+
+	err = w.DB.Create(subscription).Error
+	if err != nil {
+		return fmt.Errorf("failed to save webhook : %v", err)
+	}
+	return nil
+}
+
+// Get returns a subscription of userId.
+func (w *WebhookModel) Get(subscriptionId, userId string) (*WebhookSubscription, error) {
+	var subscription WebhookSubscription
+	result := w.DB.Where("subscription_id = ? and user_id = ?", subscriptionId, userId).Limit(1).Find(&subscription)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWebhookNotFound
+	}
+	return &subscription, nil
+}
+
+func (w *WebhookModel) ListByUser(userId string) ([]WebhookSubscription, error) {
+	var subscriptions []WebhookSubscription
+	err := w.DB.Where("user_id = ?", userId).Order("created_time").Find(&subscriptions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks : %v", err)
+	}
+	return subscriptions, nil
+}
+
+// Delete removes a subscription of userId with its deliveries not sent yet.
+func (w *WebhookModel) Delete(subscriptionId, userId string) error {
+	return w.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("delete from webhook_subscriptions where subscription_id = ? and user_id = ?", subscriptionId, userId)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to delete webhook : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+
+		err := tx.Exec("delete from webhook_deliveries where subscription_id = ? and state = ?", subscriptionId, DeliveryPending).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete deliveries : %v", err)
+		}
+		return nil
+	})
+}
+
+// Enable makes a disabled subscription of userId receive events again ; its pending deliveries
+// are sent right away.
+func (w *WebhookModel) Enable(subscriptionId, userId string) error {
+	return w.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("update webhook_subscriptions set state = ?, consecutive_failures = 0, disabled_reason = '', disabled_time = null where subscription_id = ? and user_id = ?",
+			WebhookActive, subscriptionId, userId)
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to enable webhook : %v", err)
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+
+		err := tx.Exec("update webhook_deliveries set next_attempt_time = ? where subscription_id = ? and state = ?", time.Now(), subscriptionId, DeliveryPending).Error
+		if err != nil {
+			return fmt.Errorf("failed to resume deliveries : %v", err)
+		}
+		return nil
+	})
+}
+
+// Deliveries returns the deliveries of a subscription, newest first.
+func (w *WebhookModel) Deliveries(subscriptionId string, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := w.DB.Where("subscription_id = ?", subscriptionId).Order("created_time desc").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries : %v", err)
+	}
+	return deliveries, nil
+}
+
+// GetDelivery returns a delivery of the subscription subscriptionId with its attempts.
+func (w *WebhookModel) GetDelivery(deliveryId, subscriptionId string) (*WebhookDelivery, []WebhookAttempt, error) {
+	var delivery WebhookDelivery
+	result := w.DB.Where("delivery_id = ? and subscription_id = ?", deliveryId, subscriptionId).Limit(1).Find(&delivery)
+	if err := result.Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to get delivery : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrDeliveryNotFound
+	}
+
+	var attempts []WebhookAttempt
+	err := w.DB.Where("delivery_id = ?", deliveryId).Order("attempt_id").Find(&attempts).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get delivery attempts : %v", err)
+	}
+	return &delivery, attempts, nil
+}
+
+// Redeliver queues a delivery of the subscription subscriptionId again, as a new delivery with
+// the same event id and payload.
+func (w *WebhookModel) Redeliver(deliveryId, subscriptionId string) (*WebhookDelivery, error) {
+	original, _, err := w.GetDelivery(deliveryId, subscriptionId)
+	if err != nil {
+		return nil, err
+	}
+	if original.State == DeliveryPending {
+		return nil, errors.New("delivery is still pending")
+	}
+
+	now := time.Now()
+	delivery := &WebhookDelivery{
+		DeliveryId:      uuid.NewString(),
+		SubscriptionId:  original.SubscriptionId,
+		EventId:         original.EventId,
+		EventType:       original.EventType,
+		Payload:         original.Payload,
+		State:           DeliveryPending,
+		NextAttemptTime: now,
+		RedeliveryOf:    original.DeliveryId,
+		CreatedTime:     now,
+	}
+	err = w.DB.Create(delivery).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save delivery : %v", err)
+	}
+	return delivery, nil
+}
+
+// emitWebhookEvent queues event, about accountId, for every active subscription to it : the
+// ones of the holders of the account, on all their accounts or this one. It runs in the
+// transaction of the change it reports, so that an event is sent if and only if it happened.
+func emitWebhookEvent(tx *gorm.DB, event, accountId string, data interface{}) error {
+	if IsInternalAccount(accountId) {
+		return nil
+	}
+
+	var subscriptionIds []string
+	err := tx.Raw(`select subscription_id from webhook_subscriptions
+		where state = ? and ? = any(string_to_array(events, ','))
+		and user_id in (select user_id from account_holders where account_id = ?) and (account_id = '' or account_id = ?)`,
+		WebhookActive, event, accountId, accountId).Scan(&subscriptionIds).Error
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks : %v", err)
+	}
+	if len(subscriptionIds) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	eventId := uuid.NewString()
+	payload, err := json.Marshal(webhookPayload{Id: eventId, Type: event, Created: now.UTC(), Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event : %v", err)
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(subscriptionIds))
+	for _, subscriptionId := range subscriptionIds {
+		deliveries = append(deliveries, WebhookDelivery{
+			DeliveryId:      uuid.NewString(),
+			SubscriptionId:  subscriptionId,
+			EventId:         eventId,
+			EventType:       event,
+			Payload:         string(payload),
+			State:           DeliveryPending,
+			NextAttemptTime: now,
+			CreatedTime:     now,
+		})
+	}
+	err = tx.Create(&deliveries).Error
+	if err != nil {
+		return fmt.Errorf("failed to save deliveries : %v", err)
+	}
+	return nil
+}
+
+// transactionEvent is the data of the transaction events, the fields of /api/transaction/status.
+func transactionEvent(tx *Transaction) map[string]interface{} {
+	return map[string]interface{}{
+		"transaction_id": tx.TransactionId,
+		"type":           tx.Type,
+		"state":          tx.State,
+		"reason":         tx.Reason,
+		"detail":         tx.Detail,
+		"risk_rule":      tx.RiskRule,
+		"amount":         tx.Amount,
+		"fee":            tx.Fee,
+		"sender":         tx.Sender,
+		"receiver":       tx.Receiver,
+		"created_time":   tx.CreatedTime,
+		"completed_time": tx.CompletedTime,
+		"rejected_time":  tx.RejectedTime,
+	}
+}
+
+// emitTransactionCompleted tells both parties that tx completed.
+func emitTransactionCompleted(db *gorm.DB, tx *Transaction) error {
+	data := transactionEvent(tx)
+	for _, accountId := range []string{tx.Sender, tx.Receiver} {
+		if accountId == "" {
+			continue
+		}
+		if err := emitWebhookEvent(db, EventTransactionCompleted, accountId, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitTransactionRejected tells the sender that tx was rejected.
+func emitTransactionRejected(db *gorm.DB, tx *Transaction) error {
+	return emitWebhookEvent(db, EventTransactionRejected, tx.Sender, transactionEvent(tx))
+}
+
+// Claim leases up to limit deliveries due at now to the caller for lease : other dispatchers
+// skip them until it ends, so a dispatcher which dies only delays them.
+func (w *WebhookModel) Claim(now time.Time, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(`select d.* from webhook_deliveries d join webhook_subscriptions s on s.subscription_id = d.subscription_id
+			where d.state = ? and d.next_attempt_time <= ? and s.state = ?
+			order by d.next_attempt_time limit ? for update of d skip locked`,
+			DeliveryPending, now, WebhookActive, limit).Scan(&deliveries).Error
+		if err != nil {
+			return fmt.Errorf("failed to get due deliveries : %v", err)
+		}
+
+		for _, delivery := range deliveries {
+			err = tx.Exec("update webhook_deliveries set next_attempt_time = ? where delivery_id = ?", now.Add(lease), delivery.DeliveryId).Error
+			if err != nil {
+				return fmt.Errorf("failed to lease delivery : %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Subscription returns a subscription whoever its owner, for the dispatcher.
+func (w *WebhookModel) Subscription(subscriptionId string) (*WebhookSubscription, error) {
+	var subscription WebhookSubscription
+	result := w.DB.Where("subscription_id = ?", subscriptionId).Limit(1).Find(&subscription)
+	if err := result.Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook : %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWebhookNotFound
+	}
+	return &subscription, nil
+}
+
+// RecordAttempt saves the outcome of an attempt of delivery, statusCode being 0 when the
+// endpoint couldn't be reached. A failure schedules the next attempt, or fails the delivery after
+// the last one, and disables the subscription once it failed too many times in a row.
+func (w *WebhookModel) RecordAttempt(delivery *WebhookDelivery, statusCode int, attemptErr string, duration time.Duration) error {
+	now := time.Now()
+	succeeded := statusCode >= 200 && statusCode < 300
+
+	return w.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&WebhookAttempt{
+			DeliveryId: delivery.DeliveryId,
+			StatusCode: statusCode,
+			Error:      attemptErr,
+			DurationMs: duration.Milliseconds(),
+			Time:       now,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to save delivery attempt : %v", err)
+		}
+
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		delivery.LastError = attemptErr
+
+		if succeeded {
+			delivery.State = DeliverySucceeded
+			delivery.DeliveredTime = &now
+			err = tx.Exec("update webhook_deliveries set state = ?, attempts = ?, last_status_code = ?, last_error = '', delivered_time = ? where delivery_id = ?",
+				delivery.State, delivery.Attempts, statusCode, now, delivery.DeliveryId).Error
+			if err != nil {
+				return fmt.Errorf("failed to save delivery : %v", err)
+			}
+			err = tx.Exec("update webhook_subscriptions set consecutive_failures = 0 where subscription_id = ?", delivery.SubscriptionId).Error
+			if err != nil {
+				return fmt.Errorf("failed to save webhook : %v", err)
+			}
+			return nil
+		}
+
+		if delivery.Attempts >= webhookPolicy.MaxAttempts {
+			delivery.State = DeliveryFailed
+		}
+		delivery.NextAttemptTime = now.Add(webhookPolicy.RetryDelay(delivery.Attempts))
+		err = tx.Exec("update webhook_deliveries set state = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_time = ? where delivery_id = ?",
+			delivery.State, delivery.Attempts, statusCode, attemptErr, delivery.NextAttemptTime, delivery.DeliveryId).Error
+		if err != nil {
+			return fmt.Errorf("failed to save delivery : %v", err)
+		}
+
+		err = tx.Exec("update webhook_subscriptions set consecutive_failures = consecutive_failures + 1 where subscription_id = ?", delivery.SubscriptionId).Error
+		if err != nil {
+			return fmt.Errorf("failed to save webhook : %v", err)
+		}
+		err = tx.Exec("update webhook_subscriptions set state = ?, disabled_reason = ?, disabled_time = ? where subscription_id = ? and state = ? and consecutive_failures >= ?",
+			WebhookDisabled, fmt.Sprintf("%d failed attempts in a row", webhookPolicy.DisableAfter), now, delivery.SubscriptionId, WebhookActive, webhookPolicy.DisableAfter).Error
+		if err != nil {
+			return fmt.Errorf("failed to disable webhook : %v", err)
+		}
+		return nil
+	})
+}
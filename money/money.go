@@ -0,0 +1,252 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a request or a stored row doesn't carry a currency code.
+var DefaultCurrency = "VND"
+
+// currencies maps ISO 4217 codes to the number of digits after the decimal point (minor unit exponent).
+var currencies = map[string]int{
+	"VND": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"SGD": 2,
+	"AUD": 2,
+	"CHF": 2,
+	"KWD": 3,
+	"BHD": 3,
+}
+
+// Money is an exact amount expressed in the minor units (cents, xu, ...) of a currency.
+type Money struct {
+	Units    int64
+	Currency string `gorm:"size:3"`
+}
+
+func New(units int64, currency string) Money {
+	return Money{
+		Units:    units,
+		Currency: currency,
+	}
+}
+
+func Exponent(currency string) (int, error) {
+	exp, ok := currencies[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency : %s", currency)
+	}
+	return exp, nil
+}
+
+// Parse converts a decimal string such as "1250.50" into minor units of the given currency.
+// Amounts with more fractional digits than the currency allows are rejected.
+func Parse(amount, currency string) (Money, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	currency = strings.ToUpper(currency)
+
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.TrimSpace(amount)
+	if s == "" {
+		return Money{}, errors.New("amount must not be blank")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return Money{}, fmt.Errorf("invalid amount : %s", amount)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, fmt.Errorf("invalid amount : %s", amount)
+	}
+
+	trimmed := strings.TrimRight(fracPart, "0")
+	if len(trimmed) > exp {
+		return Money{}, fmt.Errorf("amount %s has more precision than %s allows (%d decimal places)", amount, currency, exp)
+	}
+	fracPart = trimmed + strings.Repeat("0", exp-len(trimmed))
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	if digits == "" {
+		digits = "0"
+	}
+	units, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount out of range : %s", amount)
+	}
+	if negative {
+		units = -units
+	}
+
+	return New(units, currency), nil
+}
+
+// FromFloat converts a legacy floating point amount, failing when it can't be represented exactly.
+func FromFloat(amount float64, currency string) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	scaled := amount * math.Pow10(exp)
+	rounded := math.Round(scaled)
+	if math.Abs(scaled-rounded) > 1e-6 {
+		return Money{}, fmt.Errorf("amount %v has more precision than %s allows (%d decimal places)", amount, currency, exp)
+	}
+	return New(int64(rounded), strings.ToUpper(currency)), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal formats the amount in major units, e.g. 125050 USD -> "1250.50".
+func (m Money) Decimal() string {
+	exp, err := Exponent(m.Currency)
+	if err != nil {
+		exp = 0
+	}
+
+	units := m.Units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	s := strconv.FormatInt(units, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Decimal(), m.Currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Units == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Units > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Units < 0
+}
+
+func (m Money) SameCurrency(other Money) bool {
+	return strings.EqualFold(m.Currency, other.Currency)
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, fmt.Errorf("currency mismatch : %s and %s", m.Currency, other.Currency)
+	}
+	return New(m.Units+other.Units, m.Currency), nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, fmt.Errorf("currency mismatch : %s and %s", m.Currency, other.Currency)
+	}
+	return New(m.Units-other.Units, m.Currency), nil
+}
+
+func (m Money) Neg() Money {
+	return New(-m.Units, m.Currency)
+}
+
+// Cmp returns -1, 0 or 1 depending on whether m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if !m.SameCurrency(other) {
+		return 0, fmt.Errorf("currency mismatch : %s and %s", m.Currency, other.Currency)
+	}
+	switch {
+	case m.Units < other.Units:
+		return -1, nil
+	case m.Units > other.Units:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string so clients never see binary floating point.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{
+		Amount:   m.Decimal(),
+		Currency: m.Currency,
+	})
+}
+
+// UnmarshalJSON accepts {"amount": "12.34", "currency": "USD"}; amount may also be a JSON number,
+// which is parsed from its literal text rather than through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw jsonMoney
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid money value : %v", err)
+	}
+
+	amount := string(bytes.TrimSpace(raw.Amount))
+	if amount == "" || amount == "null" {
+		return errors.New("amount must not be blank")
+	}
+	if strings.HasPrefix(amount, "\"") {
+		if err := json.Unmarshal(raw.Amount, &amount); err != nil {
+			return fmt.Errorf("invalid amount : %v", err)
+		}
+	} else if strings.ContainsAny(amount, "eE") {
+		return fmt.Errorf("invalid amount : %s", amount)
+	}
+
+	parsed, err := Parse(amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
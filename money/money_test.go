@@ -0,0 +1,174 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		units    int64
+		wantErr  bool
+	}{
+		{"1250.50", "USD", 125050, false},
+		{"1250.5", "usd", 125050, false},
+		{"0.01", "USD", 1, false},
+		{".5", "USD", 50, false},
+		{"5.", "USD", 500, false},
+		{"-12.34", "EUR", -1234, false},
+		{"+7", "EUR", 700, false},
+		{"  42  ", "VND", 42, false},
+		{"50000.000", "VND", 50000, false},
+		{"1.234", "KWD", 1234, false},
+		{"007", "JPY", 7, false},
+		{"1.005", "USD", 0, true},
+		{"1.5", "VND", 0, true},
+		{"", "USD", 0, true},
+		{".", "USD", 0, true},
+		{"-", "USD", 0, true},
+		{"1,5", "USD", 0, true},
+		{"1e3", "USD", 0, true},
+		{"12", "XYZ", 0, true},
+		{"99999999999999999999", "VND", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q, %q) = %v, want an error", tt.amount, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q, %q) : %v", tt.amount, tt.currency, err)
+			continue
+		}
+		if got.Units != tt.units {
+			t.Errorf("Parse(%q, %q) = %d units, want %d", tt.amount, tt.currency, got.Units, tt.units)
+		}
+	}
+}
+
+func TestParseDefaultCurrency(t *testing.T) {
+	got, err := Parse("10", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Currency != DefaultCurrency {
+		t.Errorf("currency = %s, want %s", got.Currency, DefaultCurrency)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(125050, "USD"), "1250.50"},
+		{New(5, "USD"), "0.05"},
+		{New(-5, "USD"), "-0.05"},
+		{New(0, "USD"), "0.00"},
+		{New(1234, "KWD"), "1.234"},
+		{New(50000, "VND"), "50000"},
+		{New(-50000, "VND"), "-50000"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%d %s Decimal() = %q, want %q", tt.money.Units, tt.money.Currency, got, tt.want)
+		}
+		parsed, err := Parse(tt.want, tt.money.Currency)
+		if err != nil || parsed != tt.money {
+			t.Errorf("Parse(%q) = %v, %v, want %v back", tt.want, parsed, err, tt.money)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		units    int64
+		wantErr  bool
+	}{
+		{0.1 + 0.2, "USD", 30, false},
+		{19.99, "USD", 1999, false},
+		{50000, "VND", 50000, false},
+		{0.5, "VND", 0, true},
+		{1.001, "USD", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := FromFloat(tt.amount, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("FromFloat(%v, %s) = %v, want an error", tt.amount, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil || got.Units != tt.units {
+			t.Errorf("FromFloat(%v, %s) = %v, %v, want %d units", tt.amount, tt.currency, got, err, tt.units)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := New(150, "USD"), New(50, "usd")
+
+	if sum, err := a.Add(b); err != nil || sum.Units != 200 {
+		t.Errorf("Add = %v, %v, want 200 units", sum, err)
+	}
+	if diff, err := a.Sub(b); err != nil || diff.Units != 100 {
+		t.Errorf("Sub = %v, %v, want 100 units", diff, err)
+	}
+	if cmp, err := b.Cmp(a); err != nil || cmp != -1 {
+		t.Errorf("Cmp = %d, %v, want -1", cmp, err)
+	}
+	if _, err := a.Add(New(1, "EUR")); err == nil {
+		t.Error("Add across currencies must fail")
+	}
+	if _, err := a.Cmp(New(1, "EUR")); err == nil {
+		t.Error("Cmp across currencies must fail")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Money
+		wantErr bool
+	}{
+		{`{"amount": "12.34", "currency": "USD"}`, New(1234, "USD"), false},
+		{`{"amount": 12.34, "currency": "USD"}`, New(1234, "USD"), false},
+		{`{"amount": 0.1, "currency": "USD"}`, New(10, "USD"), false},
+		{`{"amount": 1e2, "currency": "USD"}`, Money{}, true},
+		{`{"amount": null, "currency": "USD"}`, Money{}, true},
+		{`{"currency": "USD"}`, Money{}, true},
+		{`{"amount": "1.234", "currency": "USD"}`, Money{}, true},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %v, want an error", tt.data, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", tt.data, got, err, tt.want)
+		}
+	}
+
+	encoded, err := json.Marshal(New(125050, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"amount":"1250.50","currency":"USD"}` {
+		t.Errorf("Marshal = %s", encoded)
+	}
+}
@@ -1,11 +1,15 @@
 package redis_client
 
-import "github.com/go-redis/redis"
+import (
+	"account-management/config"
 
-func InitRedisClient() *redis.Client {
+	"github.com/go-redis/redis"
+)
+
+func InitRedisClient(cfg config.RedisConfig) *redis.Client {
 	return redis.NewClient(&redis.Options{
-		Addr:     "",
-		Password: "", // no password set
-		DB:       0,  // use default DB
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 }
@@ -0,0 +1,67 @@
+package redis_client
+
+import (
+	"account-management/types"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Revocation entries only need to outlive the access tokens they cancel, so every key expires
+// after the access token lifespan.
+func revokedTokenKey(tokenId string) string {
+	return "revoked:jti:" + tokenId
+}
+
+func revokedSessionKey(sessionId string) string {
+	return "revoked:sid:" + sessionId
+}
+
+func revokedUserKey(userId string) string {
+	return "revoked:sub:" + userId
+}
+
+// RevokeToken cancels a single access token.
+func RevokeToken(rdb *redis.Client, tokenId string, ttl time.Duration) error {
+	return rdb.Set(revokedTokenKey(tokenId), 1, ttl).Err()
+}
+
+// RevokeSession cancels every access token issued for a session family.
+func RevokeSession(rdb *redis.Client, sessionId string, ttl time.Duration) error {
+	return rdb.Set(revokedSessionKey(sessionId), 1, ttl).Err()
+}
+
+// RevokeUser cancels every access token of the user issued up to now ; the time is kept in
+// nanoseconds so that a token issued right after, e.g. on a new login, stays valid.
+func RevokeUser(rdb *redis.Client, userId string, ttl time.Duration) error {
+	return rdb.Set(revokedUserKey(userId), time.Now().UnixNano(), ttl).Err()
+}
+
+// IsRevoked tells whether the access token of principal was revoked by any of the above.
+func IsRevoked(rdb *redis.Client, principal *types.Principal) (bool, error) {
+	values, err := rdb.MGet(revokedTokenKey(principal.TokenId), revokedSessionKey(principal.SessionId), revokedUserKey(principal.UserId)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if values[0] != nil || (principal.SessionId != "" && values[1] != nil) {
+		return true, nil
+	}
+
+	if revokedAt, ok := values[2].(string); ok {
+		ts, err := strconv.ParseInt(revokedAt, 10, 64)
+		if err != nil {
+			return true, nil
+		}
+		// revocations of older versions are in seconds : they cover their whole second
+		if ts < 1e12 {
+			ts = (ts+1)*int64(time.Second) - 1
+		}
+		if principal.IssuedAt.UnixNano() < ts {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,192 @@
+package redis_client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	payloadField = "payload"
+	// streamMaxLen bounds each stream approximately ; acknowledged entries are the ones trimmed first in practice.
+	streamMaxLen = 1000000
+)
+
+// StreamMessage is one entry read from a stream through a consumer group.
+type StreamMessage struct {
+	Stream  string
+	Id      string
+	Payload string
+}
+
+// Enqueue appends payload to stream and returns the generated entry id.
+func Enqueue(rdb *redis.Client, stream string, payload []byte) (string, error) {
+	return rdb.XAdd(&redis.XAddArgs{
+		Stream:       stream,
+		MaxLenApprox: streamMaxLen,
+		Values:       map[string]interface{}{payloadField: string(payload)},
+	}).Result()
+}
+
+// EnsureGroup creates the consumer group (and the stream) if needed. The group starts at the
+// beginning of the stream so entries added before the first worker ever ran are not skipped.
+func EnsureGroup(rdb *redis.Client, stream, group string) error {
+	err := rdb.XGroupCreateMkStream(stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on %s : %v", group, stream, err)
+	}
+	return nil
+}
+
+// Consumer reads streams as a named member of a consumer group. Entries stay pending until Ack,
+// so a consumer that restarts with the same name resumes with the entries it never acknowledged,
+// and entries left behind by a crashed consumer can be taken over with Reclaim.
+type Consumer struct {
+	Client  *redis.Client
+	Streams []string
+	Group   string
+	Name    string
+	Block   time.Duration
+	Count   int64
+	MinIdle time.Duration
+
+	// pendingCursor is the last pending entry id re-read after a restart, per stream ;
+	// a stream is removed once its pending list has been fully re-read.
+	pendingCursor map[string]string
+}
+
+func NewConsumer(rdb *redis.Client, streams []string, group, name string, minIdle time.Duration) *Consumer {
+	c := &Consumer{
+		Client:  rdb,
+		Streams: streams,
+		Group:   group,
+		Name:    name,
+		Block:   5 * time.Second,
+		Count:   10,
+		MinIdle: minIdle,
+
+		pendingCursor: map[string]string{},
+	}
+	for _, stream := range streams {
+		c.pendingCursor[stream] = "0"
+	}
+	return c
+}
+
+// Read returns the next batch of entries. Until its own pending list is drained the consumer
+// re-reads entries delivered to it before a restart, then it switches to new entries.
+func (c *Consumer) Read() ([]StreamMessage, error) {
+	var messages []StreamMessage
+
+	for stream, cursor := range c.pendingCursor {
+		streams, err := c.Client.XReadGroup(&redis.XReadGroupArgs{
+			Group:    c.Group,
+			Consumer: c.Name,
+			Streams:  []string{stream, cursor},
+			Count:    c.Count,
+			Block:    -1,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		pending := toStreamMessages(streams)
+		if len(pending) == 0 {
+			delete(c.pendingCursor, stream)
+			continue
+		}
+		c.pendingCursor[stream] = pending[len(pending)-1].Id
+		messages = append(messages, pending...)
+	}
+
+	if len(messages) > 0 {
+		return messages, nil
+	}
+
+	args := make([]string, 0, len(c.Streams)*2)
+	args = append(args, c.Streams...)
+	for range c.Streams {
+		args = append(args, ">")
+	}
+
+	streams, err := c.Client.XReadGroup(&redis.XReadGroupArgs{
+		Group:    c.Group,
+		Consumer: c.Name,
+		Streams:  args,
+		Count:    c.Count,
+		Block:    c.Block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toStreamMessages(streams), nil
+}
+
+// Reclaim takes over entries that were delivered but not acknowledged for at least MinIdle,
+// typically because the consumer holding them crashed or failed to record the outcome.
+func (c *Consumer) Reclaim() ([]StreamMessage, error) {
+	var messages []StreamMessage
+
+	for _, stream := range c.Streams {
+		pending, err := c.Client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  c.Group,
+			Start:  "-",
+			End:    "+",
+			Count:  100,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		var ids []string
+		for _, p := range pending {
+			if p.Idle >= c.MinIdle {
+				ids = append(ids, p.Id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		claimed, err := c.Client.XClaim(&redis.XClaimArgs{
+			Stream:   stream,
+			Group:    c.Group,
+			Consumer: c.Name,
+			MinIdle:  c.MinIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		messages = append(messages, toStreamMessages([]redis.XStream{{Stream: stream, Messages: claimed}})...)
+	}
+
+	return messages, nil
+}
+
+func (c *Consumer) Ack(message StreamMessage) error {
+	return c.Client.XAck(message.Stream, c.Group, message.Id).Err()
+}
+
+func toStreamMessages(streams []redis.XStream) []StreamMessage {
+	var messages []StreamMessage
+	for _, stream := range streams {
+		for _, m := range stream.Messages {
+			payload, _ := m.Values[payloadField].(string)
+			messages = append(messages, StreamMessage{
+				Stream:  stream.Stream,
+				Id:      m.ID,
+				Payload: payload,
+			})
+		}
+	}
+	return messages
+}
@@ -0,0 +1,74 @@
+package risk
+
+import (
+	"account-management/money"
+	"fmt"
+	"time"
+)
+
+// Decisions of the rules, from the least to the most severe : the transaction is posted, waits
+// for an analyst, or is rejected.
+const (
+	Allow  = "allow"
+	Review = "review"
+	Block  = "block"
+)
+
+var severity = map[string]int{Allow: 0, Review: 1, Block: 2}
+
+// Subject is the transaction being evaluated, with what is known of its sender.
+type Subject struct {
+	TransactionId string
+	Type          string
+	Sender        string
+	Receiver      string
+	Amount        money.Money
+	// AccountCreated is when the sender account was opened.
+	AccountCreated time.Time
+	Now            time.Time
+}
+
+// History answers the questions rules ask about the past transactions of the sender, the
+// transaction evaluated excluded.
+type History interface {
+	// Count returns the number of transactions of txType sent since, completed or on their way.
+	Count(txType string, since time.Time) (int, error)
+	// Total returns the sum and number of the completed transactions of txType sent since.
+	Total(txType string, since time.Time) (money.Money, int, error)
+	// PaidBefore tells whether a transfer to receiver was ever completed.
+	PaidBefore(receiver string) (bool, error)
+}
+
+// Rule is one check of the risk stage. Rules other than the built-in ones can be added with
+// model.SetRiskRules.
+type Rule interface {
+	Id() string
+	Evaluate(s Subject, h History) (Result, error)
+}
+
+// Result is the decision of a rule, with a detail for the analyst or the customer.
+type Result struct {
+	RuleId   string
+	Decision string
+	Detail   string
+}
+
+// Evaluate runs every rule on s and returns the most severe result, the first one on a tie.
+func Evaluate(rules []Rule, s Subject, h History) (Result, error) {
+	final := Result{Decision: Allow}
+	for _, rule := range rules {
+		result, err := rule.Evaluate(s, h)
+		if err != nil {
+			return Result{}, fmt.Errorf("risk rule %s : %v", rule.Id(), err)
+		}
+		result.RuleId = rule.Id()
+		if severity[result.Decision] > severity[final.Decision] {
+			final = result
+		}
+	}
+	return final, nil
+}
+
+func IsValidAction(action string) bool {
+	return action == Review || action == Block
+}
@@ -0,0 +1,107 @@
+package risk
+
+import (
+	"account-management/money"
+	"testing"
+	"time"
+)
+
+// fakeHistory answers every question with the same values.
+type fakeHistory struct {
+	count int
+	total money.Money
+	n     int
+	paid  bool
+}
+
+func (h fakeHistory) Count(txType string, since time.Time) (int, error) {
+	return h.count, nil
+}
+
+func (h fakeHistory) Total(txType string, since time.Time) (money.Money, int, error) {
+	return h.total, h.n, nil
+}
+
+func (h fakeHistory) PaidBefore(receiver string) (bool, error) {
+	return h.paid, nil
+}
+
+func TestRules(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(-1, 0, 0)
+
+	config := Config{
+		Velocity:    &VelocityConfig{Action: Review, Max: 3, Window: time.Hour},
+		NewPayee:    &NewPayeeConfig{Action: Review, Amount: "1000"},
+		AmountSpike: &AmountSpikeConfig{Action: Block, Factor: "5", LookbackDays: 30, MinHistory: 3},
+		NewAccount:  &NewAccountConfig{Action: Block, CoolingOff: 24 * time.Hour, Amount: "500"},
+	}
+	rules, err := config.Rules("USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := func(units int64, created time.Time) Subject {
+		return Subject{Type: "Transfer", Sender: "a", Receiver: "b", Amount: money.New(units, "USD"), AccountCreated: created, Now: now}
+	}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		history  fakeHistory
+		decision string
+		ruleId   string
+	}{
+		{"quiet", transfer(1000, old), fakeHistory{count: 1, paid: true}, Allow, ""},
+		{"velocity at the max", transfer(1000, old), fakeHistory{count: 2, paid: true}, Allow, ""},
+		{"velocity over the max", transfer(1000, old), fakeHistory{count: 3, paid: true}, Review, RuleVelocity},
+		{"small first transfer", transfer(99999, old), fakeHistory{}, Allow, ""},
+		{"large first transfer", transfer(100000, old), fakeHistory{}, Review, RuleNewPayee},
+		{"large transfer to a known payee", transfer(100000, old), fakeHistory{paid: true}, Allow, ""},
+		{"spike", transfer(5001, old), fakeHistory{paid: true, total: money.New(3000, "USD"), n: 3}, Block, RuleAmountSpike},
+		{"at the spike threshold", transfer(5000, old), fakeHistory{paid: true, total: money.New(3000, "USD"), n: 3}, Allow, ""},
+		{"spike without enough history", transfer(90000, old), fakeHistory{paid: true, total: money.New(2000, "USD"), n: 2}, Allow, ""},
+		{"new account, small amount", transfer(49999, now.Add(-time.Hour)), fakeHistory{paid: true}, Allow, ""},
+		{"new account, large amount", transfer(50000, now.Add(-time.Hour)), fakeHistory{paid: true}, Block, RuleNewAccount},
+		{"most severe wins", transfer(100000, now.Add(-time.Hour)), fakeHistory{count: 5}, Block, RuleNewAccount},
+	}
+
+	for _, tt := range tests {
+		result, err := Evaluate(rules, tt.subject, tt.history)
+		if err != nil {
+			t.Errorf("%s : %v", tt.name, err)
+			continue
+		}
+		if result.Decision != tt.decision || (tt.ruleId != "" && result.RuleId != tt.ruleId) {
+			t.Errorf("%s : got %s by %q, want %s by %q", tt.name, result.Decision, result.RuleId, tt.decision, tt.ruleId)
+		}
+	}
+}
+
+func TestConfigRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		rules   int
+		wantErr bool
+	}{
+		{"none", Config{}, 0, false},
+		{"velocity", Config{Velocity: &VelocityConfig{Action: Block, Max: 1, Window: time.Minute}}, 1, false},
+		{"allow isn't an action", Config{Velocity: &VelocityConfig{Action: Allow, Max: 1, Window: time.Minute}}, 0, true},
+		{"zero window", Config{Velocity: &VelocityConfig{Action: Block, Max: 1}}, 0, true},
+		{"factor of 1", Config{AmountSpike: &AmountSpikeConfig{Action: Review, Factor: "1", LookbackDays: 1, MinHistory: 1}}, 0, true},
+		{"negative payee amount", Config{NewPayee: &NewPayeeConfig{Action: Review, Amount: "-1"}}, 0, true},
+		{"new account without amount", Config{NewAccount: &NewAccountConfig{Action: Review, CoolingOff: time.Hour}}, 1, false},
+	}
+
+	for _, tt := range tests {
+		rules, err := tt.config.Rules("USD")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s : Rules() = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(rules) != tt.rules {
+			t.Errorf("%s : %d rules, want %d", tt.name, len(rules), tt.rules)
+		}
+	}
+}
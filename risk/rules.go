@@ -0,0 +1,215 @@
+package risk
+
+import (
+	"account-management/money"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Ids of the built-in rules, stored on the transactions they stop.
+const (
+	RuleVelocity    = "velocity"
+	RuleNewPayee    = "new_payee"
+	RuleAmountSpike = "amount_spike"
+	RuleNewAccount  = "new_account"
+)
+
+// VelocityConfig stops the transaction of a type once Max of them were sent within Window.
+type VelocityConfig struct {
+	Action string        `yaml:"action"`
+	Max    int           `yaml:"max"`
+	Window time.Duration `yaml:"window"`
+}
+
+// NewPayeeConfig stops a transfer of at least Amount to a receiver never paid before.
+type NewPayeeConfig struct {
+	Action string `yaml:"action"`
+	Amount string `yaml:"amount"`
+}
+
+// AmountSpikeConfig stops a transaction over Factor times the average of the completed ones of
+// its type over the last LookbackDays, once there are at least MinHistory of them.
+type AmountSpikeConfig struct {
+	Action       string `yaml:"action"`
+	Factor       string `yaml:"factor"`
+	LookbackDays int    `yaml:"lookback_days"`
+	MinHistory   int    `yaml:"min_history"`
+}
+
+// NewAccountConfig stops transactions of at least Amount (every one when blank) sent by
+// accounts opened less than CoolingOff ago.
+type NewAccountConfig struct {
+	Action     string        `yaml:"action"`
+	CoolingOff time.Duration `yaml:"cooling_off"`
+	Amount     string        `yaml:"amount"`
+}
+
+// Config enables the built-in rules ; a rule left out isn't run. Amounts are decimal strings in
+// account.currency.
+type Config struct {
+	Velocity    *VelocityConfig    `yaml:"velocity"`
+	NewPayee    *NewPayeeConfig    `yaml:"new_payee"`
+	AmountSpike *AmountSpikeConfig `yaml:"amount_spike"`
+	NewAccount  *NewAccountConfig  `yaml:"new_account"`
+}
+
+// Rules builds the rules enabled by c.
+func (c Config) Rules(currency string) ([]Rule, error) {
+	var rules []Rule
+
+	if v := c.Velocity; v != nil {
+		if !IsValidAction(v.Action) {
+			return nil, fmt.Errorf("velocity.action must be %s or %s", Review, Block)
+		}
+		if v.Max <= 0 || v.Window <= 0 {
+			return nil, errors.New("velocity.max and velocity.window must be positive")
+		}
+		rules = append(rules, &velocityRule{*v})
+	}
+
+	if p := c.NewPayee; p != nil {
+		if !IsValidAction(p.Action) {
+			return nil, fmt.Errorf("new_payee.action must be %s or %s", Review, Block)
+		}
+		amount, err := parseAmount("new_payee.amount", p.Amount, currency)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &newPayeeRule{action: p.Action, amount: amount})
+	}
+
+	if s := c.AmountSpike; s != nil {
+		if !IsValidAction(s.Action) {
+			return nil, fmt.Errorf("amount_spike.action must be %s or %s", Review, Block)
+		}
+		factor, ok := new(big.Rat).SetString(s.Factor)
+		if !ok || factor.Cmp(big.NewRat(1, 1)) <= 0 {
+			return nil, fmt.Errorf("amount_spike.factor must be a number greater than 1 : %s", s.Factor)
+		}
+		if s.LookbackDays <= 0 || s.MinHistory <= 0 {
+			return nil, errors.New("amount_spike.lookback_days and amount_spike.min_history must be positive")
+		}
+		rules = append(rules, &amountSpikeRule{config: *s, factor: factor})
+	}
+
+	if a := c.NewAccount; a != nil {
+		if !IsValidAction(a.Action) {
+			return nil, fmt.Errorf("new_account.action must be %s or %s", Review, Block)
+		}
+		if a.CoolingOff <= 0 {
+			return nil, errors.New("new_account.cooling_off must be positive")
+		}
+		var amount money.Money
+		if a.Amount != "" {
+			var err error
+			if amount, err = parseAmount("new_account.amount", a.Amount, currency); err != nil {
+				return nil, err
+			}
+		}
+		rules = append(rules, &newAccountRule{action: a.Action, coolingOff: a.CoolingOff, amount: amount})
+	}
+
+	return rules, nil
+}
+
+func parseAmount(name, amount, currency string) (money.Money, error) {
+	m, err := money.Parse(amount, currency)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%s : %v", name, err)
+	}
+	if m.IsNegative() {
+		return money.Money{}, fmt.Errorf("%s must not be negative", name)
+	}
+	return m, nil
+}
+
+type velocityRule struct {
+	config VelocityConfig
+}
+
+func (r *velocityRule) Id() string {
+	return RuleVelocity
+}
+
+func (r *velocityRule) Evaluate(s Subject, h History) (Result, error) {
+	count, err := h.Count(s.Type, s.Now.Add(-r.config.Window))
+	if err != nil {
+		return Result{}, err
+	}
+	if count+1 > r.config.Max {
+		return Result{Decision: r.config.Action, Detail: fmt.Sprintf("%d %s transactions within %s, at most %d allowed", count+1, s.Type, r.config.Window, r.config.Max)}, nil
+	}
+	return Result{Decision: Allow}, nil
+}
+
+type newPayeeRule struct {
+	action string
+	amount money.Money
+}
+
+func (r *newPayeeRule) Id() string {
+	return RuleNewPayee
+}
+
+func (r *newPayeeRule) Evaluate(s Subject, h History) (Result, error) {
+	if s.Type != "Transfer" || !s.Amount.SameCurrency(r.amount) || s.Amount.Units < r.amount.Units {
+		return Result{Decision: Allow}, nil
+	}
+	paid, err := h.PaidBefore(s.Receiver)
+	if err != nil {
+		return Result{}, err
+	}
+	if !paid {
+		return Result{Decision: r.action, Detail: fmt.Sprintf("first transfer to this receiver, of %s or more", r.amount)}, nil
+	}
+	return Result{Decision: Allow}, nil
+}
+
+type amountSpikeRule struct {
+	config AmountSpikeConfig
+	factor *big.Rat
+}
+
+func (r *amountSpikeRule) Id() string {
+	return RuleAmountSpike
+}
+
+func (r *amountSpikeRule) Evaluate(s Subject, h History) (Result, error) {
+	total, count, err := h.Total(s.Type, s.Now.AddDate(0, 0, -r.config.LookbackDays))
+	if err != nil {
+		return Result{}, err
+	}
+	if count < r.config.MinHistory || !total.SameCurrency(s.Amount) {
+		return Result{Decision: Allow}, nil
+	}
+
+	// amount > factor * total / count
+	threshold := new(big.Rat).Mul(r.factor, big.NewRat(total.Units, int64(count)))
+	if new(big.Rat).SetInt64(s.Amount.Units).Cmp(threshold) > 0 {
+		average := money.New(total.Units/int64(count), total.Currency)
+		return Result{Decision: r.config.Action, Detail: fmt.Sprintf("amount over %s times the average %s of %s", r.config.Factor, s.Type, average)}, nil
+	}
+	return Result{Decision: Allow}, nil
+}
+
+type newAccountRule struct {
+	action     string
+	coolingOff time.Duration
+	amount     money.Money
+}
+
+func (r *newAccountRule) Id() string {
+	return RuleNewAccount
+}
+
+func (r *newAccountRule) Evaluate(s Subject, h History) (Result, error) {
+	if s.Now.Sub(s.AccountCreated) >= r.coolingOff {
+		return Result{Decision: Allow}, nil
+	}
+	if r.amount.Currency != "" && (!s.Amount.SameCurrency(r.amount) || s.Amount.Units < r.amount.Units) {
+		return Result{Decision: Allow}, nil
+	}
+	return Result{Decision: r.action, Detail: fmt.Sprintf("account opened less than %s ago", r.coolingOff)}, nil
+}
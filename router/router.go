@@ -1,10 +1,12 @@
 package router
 
 import (
+	"account-management/config"
 	"account-management/controller"
 	"account-management/db"
 	"account-management/middlewares"
 	"account-management/model"
+	"fmt"
 	"log"
 
 	re "account-management/redis"
@@ -14,41 +16,103 @@ import (
 
 type ApiServer struct {
 	*controller.AccountService
+	Port int
 }
 
-func InitAPIServer(messageChannels []string) *ApiServer {
-	db := db.InitDB()
-	accountModel := model.NewAccountModel(db)
-	transactionModel := model.NewTransactionModel(db)
-	redisClient := re.InitRedisClient()
+func InitAPIServer(cfg *config.Config, messageChannels []string) *ApiServer {
+	db := db.InitDB(cfg.Database)
+	redisClient := re.InitRedisClient(cfg.Redis)
 
-	accountService := controller.NewAccountService(accountModel, transactionModel, redisClient, messageChannels)
+	accountService := controller.NewAccountService(db, redisClient, messageChannels)
 
 	return &ApiServer{
 		AccountService: accountService,
+		Port:           cfg.Server.Port,
 	}
 }
 
 func (a *ApiServer) Start() {
 	r := gin.Default()
+	r.Use(middlewares.RequestIdMiddleware())
 	r.Static("/public", "./public")
 
-	public := r.Group("/api/admin")
+	public := r.Group("/api/auth")
 
 	protected := r.Group("/api")
 
+	admin := r.Group("/api/admin")
+
 	public.POST("/register", a.Register)
 	public.POST("/login", a.Login)
+	public.POST("/refresh", a.RefreshToken)
 
-	protected.Use(middlewares.JwtAuthMiddleware())
-	protected.GET("/accounts", a.GetAllAccounts)
+	protected.Use(middlewares.JwtAuthMiddleware(a.RedisClient))
+	protected.POST("/logout", a.Logout)
+	protected.POST("/logout-all", a.LogoutAll)
 	protected.POST("/deposit", a.Deposit)
 	protected.POST("/withdraw", a.Withdraw)
 	protected.POST("/transfer", a.Transfer)
 	protected.GET("/transaction/status", a.CheckTransactionStatus)
+	protected.GET("/transactions", a.ListTransactions)
+	protected.GET("/statements", a.GetStatement)
+	protected.GET("/accounts", a.ListAccounts)
+	protected.POST("/accounts", a.OpenAccount)
+	protected.PUT("/accounts/:account_id/default", a.ChangeDefaultAccount)
+	protected.GET("/accounts/:account_id/holders", a.ListAccountHolders)
+	protected.PUT("/accounts/:account_id/holders/:username", a.SetAccountHolder)
+	protected.DELETE("/accounts/:account_id/holders/:username", a.RemoveAccountHolder)
+	protected.GET("/accounts/:account_id/approval-policies", a.ListApprovalPolicies)
+	protected.PUT("/accounts/:account_id/approval-policies/:type", a.SetApprovalPolicy)
+	protected.DELETE("/accounts/:account_id/approval-policies/:type", a.DeleteApprovalPolicy)
+	protected.GET("/approvals", a.ListPendingApprovals)
+	protected.POST("/approvals/:transaction_id", a.DecideApproval)
+	protected.POST("/payees/confirm", a.ConfirmPayee)
+	protected.POST("/beneficiaries", a.CreateBeneficiary)
+	protected.GET("/beneficiaries", a.ListBeneficiaries)
+	protected.PUT("/beneficiaries/:beneficiary_id", a.RenameBeneficiary)
+	protected.POST("/beneficiaries/:beneficiary_id/verify", a.VerifyBeneficiary)
+	protected.DELETE("/beneficiaries/:beneficiary_id", a.DeleteBeneficiary)
 	protected.GET("/account/balance", a.CheckAccountBalance)
+	protected.GET("/account/limits", a.CheckAccountLimits)
+	protected.GET("/account/funds", a.CheckAvailableFunds)
+	protected.GET("/fees/quote", a.QuoteFee)
+	protected.GET("/account/interest", a.CheckAccruedInterest)
+	protected.POST("/standing-orders", a.CreateStandingOrder)
+	protected.GET("/standing-orders", a.ListStandingOrders)
+	protected.DELETE("/standing-orders/:standing_order_id", a.CancelStandingOrder)
+	protected.GET("/standing-orders/:standing_order_id/executions", a.GetStandingOrderExecutions)
+	protected.POST("/holds", a.PlaceHold)
+	protected.GET("/holds", a.ListHolds)
+	protected.POST("/holds/:hold_id/capture", a.CaptureHold)
+	protected.POST("/holds/:hold_id/release", a.ReleaseHold)
+	protected.POST("/webhooks", a.CreateWebhook)
+	protected.GET("/webhooks", a.ListWebhooks)
+	protected.DELETE("/webhooks/:webhook_id", a.DeleteWebhook)
+	protected.POST("/webhooks/:webhook_id/enable", a.EnableWebhook)
+	protected.GET("/webhooks/:webhook_id/deliveries", a.ListWebhookDeliveries)
+	protected.GET("/webhooks/:webhook_id/deliveries/:delivery_id", a.GetWebhookDelivery)
+	protected.POST("/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", a.RedeliverWebhook)
+
+	admin.Use(middlewares.JwtAuthMiddleware(a.RedisClient))
+	admin.GET("/accounts", middlewares.RequirePermission(model.PermissionReadAccounts), a.GetAllAccounts)
+	admin.GET("/accounts/:account_id", middlewares.RequirePermission(model.PermissionReadAccounts), a.GetAccount)
+	admin.GET("/accounts/:account_id/transactions", middlewares.RequirePermission(model.PermissionReadTransactions), a.GetAccountTransactions)
+	admin.PUT("/accounts/:account_id/state", middlewares.RequirePermission(model.PermissionWriteAccountState), a.ChangeAccountState)
+	admin.GET("/accounts/:account_id/state-history", middlewares.RequirePermission(model.PermissionReadAccounts), a.GetAccountStateHistory)
+	admin.PUT("/users/:user_id/role", middlewares.RequirePermission(model.PermissionWriteRoles), a.ChangeUserRole)
+	admin.GET("/accounts/:account_id/limits", middlewares.RequirePermission(model.PermissionReadAccounts), a.GetAccountLimits)
+	admin.PUT("/accounts/:account_id/limits/:type", middlewares.RequirePermission(model.PermissionWriteLimits), a.SetAccountLimits)
+	admin.DELETE("/accounts/:account_id/limits/:type", middlewares.RequirePermission(model.PermissionWriteLimits), a.DeleteAccountLimits)
+	admin.PUT("/accounts/:account_id/tier", middlewares.RequirePermission(model.PermissionWriteLimits), a.ChangeAccountTier)
+	admin.PUT("/accounts/:account_id/floor", middlewares.RequirePermission(model.PermissionWriteLimits), a.ChangeAccountFloor)
+	admin.PUT("/accounts/:account_id/product", middlewares.RequirePermission(model.PermissionWriteProducts), a.ChangeAccountProduct)
+	admin.POST("/transactions/:transaction_id/reversals", middlewares.RequirePermission(model.PermissionReverseTransactions), a.ReverseTransaction)
+	admin.GET("/transactions/:transaction_id/reversals", middlewares.RequirePermission(model.PermissionReadTransactions), a.GetTransactionReversals)
+	admin.GET("/risk/cases", middlewares.RequirePermission(model.PermissionReadRiskCases), a.ListRiskCases)
+	admin.POST("/risk/cases/:case_id", middlewares.RequirePermission(model.PermissionReviewRiskCases), a.DecideRiskCase)
+	admin.GET("/audit", middlewares.RequirePermission(model.PermissionReadAuditLog), a.GetAuditLog)
 
-	err := r.Run(":8080") // Ứng dụng chạy tại cổng 8080
+	err := r.Run(fmt.Sprintf(":%d", a.Port))
 	if err != nil {
 		log.Fatal(err)
 	}
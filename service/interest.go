@@ -0,0 +1,149 @@
+package service
+
+import (
+	"account-management/config"
+	"account-management/controller"
+	"account-management/db"
+	"account-management/model"
+	re "account-management/redis"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InterestRun accrues the daily interest of every account and, on the last day of a month, pays
+// the accrued interest as Interest transactions processed by the task queue.
+type InterestRun struct {
+	accountService *controller.AccountService
+}
+
+func NewInterestRun(cfg *config.Config, messageChannels []string) *InterestRun {
+	db := db.InitDB(cfg.Database)
+	redisClient := re.InitRedisClient(cfg.Redis)
+
+	return &InterestRun{
+		accountService: controller.NewAccountService(db, redisClient, messageChannels),
+	}
+}
+
+func isLastDayOfMonth(date time.Time) bool {
+	return date.AddDate(0, 0, 1).Month() != date.Month()
+}
+
+// Run accrues the interest of date and capitalises it on the last day of the month. Every step
+// is idempotent : running it again for the same date, e.g. after a crash, pays nothing twice.
+func (r *InterestRun) Run(date time.Time) error {
+	day := model.Day(date)
+
+	accrued, err := r.accountService.InterestModel.Accrue(day)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Accrued interest of %s for %d accounts\n", day.Format("2006-01-02"), accrued)
+
+	if !isLastDayOfMonth(day) {
+		return nil
+	}
+
+	if err := r.accountService.RedisClient.Ping().Err(); err != nil {
+		return fmt.Errorf("task queue is unavailable, run it again to pay the interest : %v", err)
+	}
+
+	paid, err := r.capitalise(day)
+	fmt.Printf("Paid the interest of %s to %d accounts\n", day.Format("2006-01"), paid)
+	return err
+}
+
+// capitalise pays the unpaid interest accrued up to the end of the month of day.
+func (r *InterestRun) capitalise(day time.Time) (int, error) {
+	end := day.AddDate(0, 0, 1)
+
+	unpaid, err := r.accountService.InterestModel.Unpaid(end)
+	if err != nil {
+		return 0, err
+	}
+
+	paid := 0
+	var failures []error
+	for _, u := range unpaid {
+		ok, err := r.pay(u, day, end)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("account %s : %v", u.AccountId, err))
+			continue
+		}
+		if ok {
+			paid++
+		}
+	}
+
+	if len(failures) > 0 {
+		return paid, fmt.Errorf("failed to pay the interest of %d accounts, first error : %v", len(failures), failures[0])
+	}
+	return paid, nil
+}
+
+// pay submits the Interest (or OverdraftInterest) transaction of one account for the month of day, unless it was
+// already submitted, and links the accruals it pays to it. Amounts below the minor unit stay
+// unpaid until they add up.
+func (r *InterestRun) pay(u model.UnpaidInterest, day, end time.Time) (bool, error) {
+	transactionModel := r.accountService.TransactionModel
+	key := fmt.Sprintf("interest:%s", day.Format("2006-01"))
+
+	tx, err := transactionModel.GetByIdempotencyKey(u.AccountId, key)
+	if err != nil {
+		return false, err
+	}
+
+	if tx == nil {
+		amount := u.Amount()
+		if amount.IsZero() {
+			return false, nil
+		}
+
+		tx = &model.Transaction{
+			TransactionId:  uuid.NewString(),
+			Type:           "Interest",
+			Sender:         u.AccountId,
+			Amount:         amount,
+			IdempotencyKey: key,
+		}
+		if amount.IsNegative() {
+			tx.Type = "OverdraftInterest"
+			tx.Amount = amount.Neg()
+		}
+		_, submitErr := r.accountService.SubmitTransaction(tx, "")
+
+		var rejection *model.RejectionError
+		if errors.As(submitErr, &rejection) {
+			// e.g. a frozen account : its interest is paid with the next capitalisation
+			return false, nil
+		}
+		if submitErr != nil {
+			// another run may have submitted it first
+			tx, err = transactionModel.GetByIdempotencyKey(u.AccountId, key)
+			if err != nil {
+				return false, err
+			}
+			if tx == nil {
+				return false, submitErr
+			}
+		}
+	}
+
+	// only the accruals of a transaction which will be posted are paid
+	if tx.State == model.TransactionRejected || tx.State == model.TransactionFailed {
+		return false, nil
+	}
+
+	paid := tx.Amount
+	if tx.Type == "OverdraftInterest" {
+		paid = paid.Neg()
+	}
+	err = r.accountService.InterestModel.MarkPaid(u.AccountId, end, tx.TransactionId, model.InterestMicros(paid))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
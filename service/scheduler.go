@@ -0,0 +1,165 @@
+package service
+
+import (
+	"account-management/config"
+	"account-management/controller"
+	"account-management/db"
+	"account-management/model"
+	re "account-management/redis"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// dueBatchSize is the number of due standing orders handled per query.
+const dueBatchSize = 100
+
+// Scheduler runs the standing orders that are due, by submitting their transfers through the
+// same path as the transfer endpoint, and expires the holds past their expiry. Several
+// schedulers may run at once.
+type Scheduler struct {
+	Interval       time.Duration
+	accountService *controller.AccountService
+}
+
+func NewScheduler(cfg *config.Config, interval time.Duration, messageChannels []string) *Scheduler {
+	db := db.InitDB(cfg.Database)
+	redisClient := re.InitRedisClient(cfg.Redis)
+
+	return &Scheduler{
+		Interval:       interval,
+		accountService: controller.NewAccountService(db, redisClient, messageChannels),
+	}
+}
+
+func (s *Scheduler) Start() {
+	fmt.Printf("Started scheduler, checking standing orders every %s !\n", s.Interval)
+
+	for {
+		count, err := s.RunDue(time.Now())
+		if err != nil {
+			fmt.Println(err)
+		}
+		if count > 0 {
+			fmt.Printf("Ran %d standing orders\n", count)
+		}
+
+		expired, err := s.accountService.HoldModel.Expire(time.Now())
+		if err != nil {
+			fmt.Println(err)
+		}
+		if expired > 0 {
+			fmt.Printf("Expired %d holds\n", expired)
+		}
+		time.Sleep(s.Interval)
+	}
+}
+
+// RunDue runs every standing order due on or before now, including the runs missed while no
+// scheduler was running, and returns the number of executions.
+func (s *Scheduler) RunDue(now time.Time) (int, error) {
+	if err := s.accountService.RedisClient.Ping().Err(); err != nil {
+		return 0, fmt.Errorf("task queue is unavailable, standing orders will run later : %v", err)
+	}
+
+	count := 0
+	for {
+		ids, err := s.accountService.StandingOrderModel.Due(now, dueBatchSize)
+		if err != nil {
+			return count, err
+		}
+		if len(ids) == 0 {
+			return count, nil
+		}
+
+		ran := 0
+		for _, id := range ids {
+			executed, err := s.execute(id, now)
+			if err != nil {
+				log.Printf("standing order %s : %v", id, err)
+				continue
+			}
+			if executed {
+				ran++
+			}
+		}
+
+		count += ran
+		// the remaining orders are locked by other schedulers or keep failing
+		if ran == 0 {
+			return count, nil
+		}
+	}
+}
+
+// execute submits the transfer of the current run of a standing order and moves the order to
+// its next run. The transfer's Idempotency-Key is derived from the run, so a run interrupted
+// after submitting is recorded with its transaction instead of being submitted twice.
+func (s *Scheduler) execute(standingOrderId string, now time.Time) (bool, error) {
+	standingOrderModel := s.accountService.StandingOrderModel
+	transactionModel := s.accountService.TransactionModel
+
+	executed := false
+	err := standingOrderModel.DB.Transaction(func(dbTx *gorm.DB) error {
+		order, err := standingOrderModel.LockDue(standingOrderId, now, dbTx)
+		if err != nil || order == nil {
+			return err
+		}
+
+		key := fmt.Sprintf("standing-order:%s:%s", order.StandingOrderId, order.NextRunDate.Format("2006-01-02"))
+		execution := &model.StandingOrderExecution{}
+
+		tx, err := transactionModel.GetByIdempotencyKey(order.AccountId, key)
+		if err != nil {
+			return err
+		}
+
+		if tx == nil {
+			tx = &model.Transaction{
+				TransactionId:  uuid.NewString(),
+				Type:           "Transfer",
+				Sender:         order.AccountId,
+				Receiver:       order.Receiver,
+				Amount:         order.Amount,
+				IdempotencyKey: key,
+			}
+			_, submitErr := s.accountService.SubmitTransaction(tx, order.CreatedBy)
+
+			var rejection *model.RejectionError
+			if errors.As(submitErr, &rejection) {
+				execution.State = model.ExecutionRejected
+				execution.Reason = rejection.Reason
+				execution.Detail = rejection.Message
+				tx = nil
+			} else if submitErr != nil {
+				// a transaction saved before the error (e.g. the queue went away) carries the outcome
+				tx, err = transactionModel.GetByIdempotencyKey(order.AccountId, key)
+				if err != nil {
+					return err
+				}
+				if tx == nil {
+					return submitErr
+				}
+			}
+		}
+
+		if tx != nil {
+			execution.State = model.ExecutionSubmitted
+			execution.TransactionId = tx.TransactionId
+		}
+
+		err = standingOrderModel.RecordExecution(order, execution, dbTx)
+		if err != nil {
+			return err
+		}
+
+		executed = true
+		return nil
+	})
+
+	return executed, err
+}
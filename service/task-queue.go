@@ -0,0 +1,349 @@
+package service
+
+import (
+	"account-management/config"
+	"account-management/controller"
+	"account-management/db"
+	"account-management/model"
+	re "account-management/redis"
+	"account-management/risk"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// consumerGroup is the Redis Streams consumer group shared by every queue process.
+const consumerGroup = "workers"
+
+type TaskQueue struct {
+	UseWorker       bool
+	NumOfWorkers    int
+	MessageChannels []string
+	ConsumerName    string
+	ReclaimAfter    time.Duration
+	accountService  *controller.AccountService
+}
+
+func NewTaskQueue(cfg *config.Config, useWorker bool, numOfWorkers int, consumerName string, reclaimAfter time.Duration, messageChannels []string) *TaskQueue {
+	db := db.InitDB(cfg.Database)
+	redisClient := re.InitRedisClient(cfg.Redis)
+	accountService := controller.NewAccountService(db, redisClient, messageChannels)
+
+	return &TaskQueue{
+		UseWorker:       useWorker,
+		NumOfWorkers:    numOfWorkers,
+		MessageChannels: messageChannels,
+		ConsumerName:    consumerName,
+		ReclaimAfter:    reclaimAfter,
+		accountService:  accountService,
+	}
+}
+
+func (t *TaskQueue) Start() {
+	rdb := t.accountService.RedisClient
+
+	if err := rdb.Ping().Err(); err != nil {
+		log.Fatalln("Redis server is busy !")
+	}
+
+	for _, stream := range t.MessageChannels {
+		if err := re.EnsureGroup(rdb, stream, consumerGroup); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if t.UseWorker {
+		fmt.Printf("Started task queue with %d workers !\n", t.NumOfWorkers)
+
+		var wg sync.WaitGroup
+		for i := 1; i <= t.NumOfWorkers; i++ {
+			// every worker keeps the same consumer name across restarts so it resumes its own pending entries
+			consumer := re.NewConsumer(rdb, t.MessageChannels, consumerGroup, fmt.Sprintf("%s-%d", t.ConsumerName, i), t.ReclaimAfter)
+
+			wg.Add(1)
+			go func(workerId int) {
+				defer wg.Done()
+				ProcessWithWorkers(consumer, t.accountService, workerId)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		fmt.Printf("Started task queue without worker !\n")
+
+		consumer := re.NewConsumer(rdb, t.MessageChannels, consumerGroup, t.ConsumerName, t.ReclaimAfter)
+		lastReclaim := time.Now()
+		for {
+			for _, message := range nextMessages(consumer, &lastReclaim) {
+				err := ProcessWithoutWorker(consumer, message, t.accountService)
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
+		}
+	}
+
+}
+
+// nextMessages returns entries abandoned by crashed consumers when it is time to look for them,
+// otherwise the next entries delivered to consumer.
+func nextMessages(consumer *re.Consumer, lastReclaim *time.Time) []re.StreamMessage {
+	if time.Since(*lastReclaim) >= consumer.MinIdle {
+		*lastReclaim = time.Now()
+
+		messages, err := consumer.Reclaim()
+		if err != nil {
+			fmt.Println(err)
+		} else if len(messages) > 0 {
+			return messages
+		}
+	}
+
+	messages, err := consumer.Read()
+	if err != nil {
+		fmt.Println(err)
+		time.Sleep(time.Second)
+		return nil
+	}
+	return messages
+}
+
+// handleMessage processes one stream entry and acknowledges it once its outcome is stored.
+// Entries whose outcome couldn't be recorded stay pending and are retried through Reclaim.
+func handleMessage(consumer *re.Consumer, message re.StreamMessage, accountService *controller.AccountService) (*model.Transaction, error) {
+
+	var tx model.Transaction
+
+	err := json.Unmarshal([]byte(message.Payload), &tx)
+	if err != nil {
+		consumer.Ack(message)
+		return nil, fmt.Errorf("dropping malformed message %s : %v", message.Id, err)
+	}
+
+	current, err := accountService.TransactionModel.GetTransaction(tx.TransactionId)
+	if errors.Is(err, model.ErrTransactionNotFound) {
+		consumer.Ack(message)
+		return nil, fmt.Errorf("dropping message %s : %v", message.Id, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.State != model.TransactionPending {
+		consumer.Ack(message)
+		return nil, fmt.Errorf("skipping message %s : transaction %s is already %s", message.Id, tx.TransactionId, current.State)
+	}
+
+	err = ProcessTransactionWithWorkers(accountService, &tx)
+	if tx.State != model.TransactionPending || errors.Is(err, model.ErrTransactionNotPending) {
+		if ackErr := consumer.Ack(message); ackErr != nil {
+			fmt.Println(ackErr)
+		}
+	}
+
+	return &tx, err
+}
+
+func ProcessWithWorkers(consumer *re.Consumer, accountService *controller.AccountService, workerId int) {
+
+	lastReclaim := time.Now()
+	for {
+		for _, message := range nextMessages(consumer, &lastReclaim) {
+			tx, err := handleMessage(consumer, message, accountService)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			switch tx.Type {
+			case "Transfer":
+				fmt.Printf("Worker %d : %s transfered %s to %s\n", workerId, tx.Sender, tx.Amount, tx.Receiver)
+			case "Deposit":
+				fmt.Printf("Worker %d : %s deposited %s to account\n", workerId, tx.Sender, tx.Amount)
+			case "Withdraw":
+				fmt.Printf("Worker %d : %s withdrew %s from account\n", workerId, tx.Sender, tx.Amount)
+			case "Interest":
+				fmt.Printf("Worker %d : %s earned %s of interest\n", workerId, tx.Sender, tx.Amount)
+			case "OverdraftInterest":
+				fmt.Printf("Worker %d : %s was charged %s of overdraft interest\n", workerId, tx.Sender, tx.Amount)
+			case "Reversal":
+				fmt.Printf("Worker %d : %s reversed %s of transaction %s\n", workerId, tx.TransactionId, tx.Amount, tx.ReversalOf)
+			}
+		}
+	}
+
+}
+
+func ProcessWithoutWorker(consumer *re.Consumer, message re.StreamMessage, accountService *controller.AccountService) error {
+
+	tx, err := handleMessage(consumer, message, accountService)
+	if err != nil {
+		return err
+	}
+
+	switch tx.Type {
+	case "Transfer":
+		fmt.Printf("%s transfered %s to %s\n", tx.Sender, tx.Amount, tx.Receiver)
+	case "Deposit":
+		fmt.Printf("%s deposited %s to account\n", tx.Sender, tx.Amount)
+	case "Withdraw":
+		fmt.Printf("%s withdrew %s from account\n", tx.Sender, tx.Amount)
+	case "Interest":
+		fmt.Printf("%s earned %s of interest\n", tx.Sender, tx.Amount)
+	case "OverdraftInterest":
+		fmt.Printf("%s was charged %s of overdraft interest\n", tx.Sender, tx.Amount)
+	case "Reversal":
+		fmt.Printf("%s reversed %s of transaction %s\n", tx.TransactionId, tx.Amount, tx.ReversalOf)
+	}
+
+	return nil
+
+}
+
+func ProcessTransactionWithWorkers(accountService *controller.AccountService, tx *model.Transaction) error {
+	ledgerModel := accountService.LedgerModel
+	transactionModel := accountService.TransactionModel
+
+	postings, err := model.TransactionPostings(tx)
+	if err != nil {
+		if finishErr := transactionModel.Finish(tx, err); finishErr != nil {
+			fmt.Println(finishErr)
+		}
+		return err
+	}
+
+	// the risk stage runs first : a transaction it blocks or sends for review is saved as such
+	// and never posted
+	decision, err := accountService.RiskModel.Screen(tx, transactionModel)
+	if errors.Is(err, model.ErrTransactionNotPending) || errors.Is(err, model.ErrTransactionNotFound) {
+		return fmt.Errorf("skipping transaction %s : %w", tx.TransactionId, err)
+	}
+	if err != nil {
+		if finishErr := transactionModel.Finish(tx, err); finishErr != nil {
+			fmt.Println(finishErr)
+		}
+		return fmt.Errorf("transaction %s %s : %v", tx.TransactionId, strings.ToLower(tx.State), err)
+	}
+	if decision != risk.Allow {
+		return fmt.Errorf("transaction %s %s by risk rule %s : %s", tx.TransactionId, strings.ToLower(tx.State), tx.RiskRule, tx.Detail)
+	}
+
+	err = ledgerModel.DB.Transaction(func(dbTx *gorm.DB) error {
+
+		err := transactionModel.LockPending(tx, dbTx)
+		if err != nil {
+			return err
+		}
+
+		// every account of the transaction is locked up front, in a fixed order : the limits
+		// and the postings below lock them again one at a time
+		accountIds := make([]string, 0, len(postings))
+		for _, p := range postings {
+			accountIds = append(accountIds, p.AccountId)
+		}
+		err = accountService.AccountModel.LockAccounts(accountIds, dbTx)
+		if err != nil {
+			return err
+		}
+
+		// a capture closes its hold first, so the hold stops reserving the funds posted below
+		if tx.HoldId != "" {
+			err = accountService.HoldModel.Capture(tx, dbTx)
+			if err != nil {
+				return err
+			}
+		}
+
+		var feePostings []model.Posting
+		if tx.Type == "Reversal" {
+			// reversals are decided by an admin : no limit nor fee, only the amount left to reverse ;
+			// the one completing it gives the fee of the reversed transaction back
+			feePostings, err = transactionModel.CheckReversal(tx, dbTx)
+			if err != nil {
+				return err
+			}
+		} else {
+			// limits are checked on the posting's transaction so the amounts they count can't change
+			if !model.CreditsSender(tx.Type) {
+				err = accountService.LimitModel.Check(tx, dbTx)
+				if err != nil {
+					return err
+				}
+			}
+
+			quote, err := accountService.FeeModel.Quote(tx.Sender, tx.Type, tx.Amount, dbTx)
+			if err != nil {
+				return err
+			}
+			tx.Fee = quote.Fee
+		}
+
+		entry := &model.JournalEntry{
+			TransactionId: tx.TransactionId,
+			Description:   tx.Type,
+		}
+
+		err = ledgerModel.Post(entry, postings, dbTx)
+		if err != nil {
+			return err
+		}
+
+		// the fee is a separate entry of the transaction so statements show it on its own line
+		var feeEntry *model.JournalEntry
+		description := tx.Type + " fee refund"
+		if tx.Type != "Reversal" {
+			feePostings = model.FeePostings(tx)
+			description = tx.Type + " fee"
+		}
+		if len(feePostings) > 0 {
+			feeEntry = &model.JournalEntry{
+				TransactionId: tx.TransactionId,
+				Kind:          model.EntryKindFee,
+				Description:   description,
+			}
+			err = ledgerModel.Post(feeEntry, feePostings, dbTx)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = transactionModel.Complete(tx, dbTx)
+		if err != nil {
+			return err
+		}
+
+		// audited last : the audit log stays locked from the append to the commit
+		err = ledgerModel.AuditPostings(entry, postings, dbTx)
+		if err != nil {
+			return err
+		}
+		if feeEntry != nil {
+			return ledgerModel.AuditPostings(feeEntry, feePostings, dbTx)
+		}
+		return nil
+	})
+
+	if errors.Is(err, model.ErrTransactionNotPending) || errors.Is(err, model.ErrTransactionNotFound) {
+		return fmt.Errorf("skipping transaction %s : %w", tx.TransactionId, err)
+	}
+
+	if err != nil {
+		if finishErr := transactionModel.Finish(tx, err); finishErr != nil {
+			fmt.Println(finishErr)
+		}
+		return fmt.Errorf("transaction %s %s : %v", tx.TransactionId, strings.ToLower(tx.State), err)
+	}
+
+	return nil
+}
+
+// ProcessTransactionWithoutWorker processes tx on the caller's goroutine. It shares the posting
+// path of the workers so that every balance change is journaled the same way.
+func ProcessTransactionWithoutWorker(accountService *controller.AccountService, tx *model.Transaction) error {
+	return ProcessTransactionWithWorkers(accountService, tx)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"account-management/config"
+	"account-management/db"
+	"account-management/model"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// deliveryBatchSize is the number of due deliveries sent at once.
+const deliveryBatchSize = 20
+
+// Headers of the requests posted to webhook endpoints.
+const (
+	WebhookIdHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookDispatcher posts the pending webhook deliveries to their endpoints and retries the
+// failed ones. Several dispatchers may run at once.
+type WebhookDispatcher struct {
+	Interval     time.Duration
+	Timeout      time.Duration
+	client       *http.Client
+	webhookModel *model.WebhookModel
+}
+
+func NewWebhookDispatcher(cfg *config.Config, interval time.Duration) *WebhookDispatcher {
+	db := db.InitDB(cfg.Database)
+
+	return &WebhookDispatcher{
+		Interval:     interval,
+		Timeout:      cfg.Webhooks.Timeout,
+		client:       newWebhookClient(cfg.Webhooks.Timeout),
+		webhookModel: model.NewWebhookModel(db),
+	}
+}
+
+// newWebhookClient returns the client posting to the endpoints. It only connects to public
+// addresses, checked once the host is resolved so that a name can't be pointed at the internal
+// network after it was subscribed, and doesn't follow redirects : a 3xx answer is a failure.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !model.IsPublicIP(ip) {
+				return fmt.Errorf("%s isn't a public address", host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (d *WebhookDispatcher) Start() {
+	fmt.Printf("Started webhook dispatcher, checking deliveries every %s !\n", d.Interval)
+
+	for {
+		count, err := d.DeliverDue(time.Now())
+		if err != nil {
+			fmt.Println(err)
+		}
+		if count > 0 {
+			fmt.Printf("Sent %d webhook deliveries\n", count)
+		}
+		time.Sleep(d.Interval)
+	}
+}
+
+// DeliverDue sends every delivery due on or before now and returns the number of attempts made.
+func (d *WebhookDispatcher) DeliverDue(now time.Time) (int, error) {
+	count := 0
+	for {
+		// a delivery stays leased while its attempt may still be running
+		deliveries, err := d.webhookModel.Claim(now, deliveryBatchSize, 2*d.Timeout+time.Minute)
+		if err != nil {
+			return count, err
+		}
+		if len(deliveries) == 0 {
+			return count, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(delivery *model.WebhookDelivery) {
+				defer wg.Done()
+				if err := d.deliver(delivery); err != nil {
+					fmt.Printf("webhook delivery %s : %v\n", delivery.DeliveryId, err)
+				}
+			}(&deliveries[i])
+		}
+		wg.Wait()
+
+		count += len(deliveries)
+	}
+}
+
+// deliver makes one attempt of delivery and records its outcome.
+func (d *WebhookDispatcher) deliver(delivery *model.WebhookDelivery) error {
+	subscription, err := d.webhookModel.Subscription(delivery.SubscriptionId)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	statusCode, attemptErr := d.post(subscription, delivery, start)
+	duration := time.Since(start)
+
+	errMessage := ""
+	if attemptErr != nil {
+		errMessage = attemptErr.Error()
+	} else if statusCode < 200 || statusCode >= 300 {
+		errMessage = fmt.Sprintf("endpoint answered %d", statusCode)
+	}
+
+	return d.webhookModel.RecordAttempt(delivery, statusCode, errMessage, duration)
+}
+
+// post sends the payload of delivery to the endpoint of subscription and returns the status
+// code it answered, 0 when it couldn't be reached.
+func (d *WebhookDispatcher) post(subscription *model.WebhookSubscription, delivery *model.WebhookDelivery, now time.Time) (int, error) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	request, err := http.NewRequest(http.MethodPost, subscription.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	if request.URL.Scheme != "https" {
+		return 0, errors.New("url must be an https url")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "account-management-webhooks")
+	request.Header.Set(WebhookIdHeader, delivery.EventId)
+	request.Header.Set(WebhookEventHeader, delivery.EventType)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	// the body is read, at most 64KB of it, so that the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10))
+
+	return response.StatusCode, nil
+}
+
+// SignWebhook returns the signature of a payload sent at timestamp : sha256= followed by the hex
+// HMAC-SHA256, keyed with the secret of the subscription, of the timestamp, a dot and the payload.
+func SignWebhook(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
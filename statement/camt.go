@@ -0,0 +1,143 @@
+package statement
+
+import (
+	"account-management/model"
+	"account-management/money"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ISO 20022 Bank to Customer Statement, camt.053.001.02.
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+type camtDocument struct {
+	XMLName   xml.Name      `xml:"Document"`
+	Namespace string        `xml:"xmlns,attr"`
+	GroupHdr  camtGroupHdr  `xml:"BkToCstmrStmt>GrpHdr"`
+	Statement camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtGroupHdr struct {
+	MessageId   string `xml:"MsgId"`
+	CreatedTime string `xml:"CreDtTm"`
+}
+
+type camtStatement struct {
+	Id          string        `xml:"Id"`
+	CreatedTime string        `xml:"CreDtTm"`
+	From        string        `xml:"FrToDt>FrDtTm"`
+	To          string        `xml:"FrToDt>ToDtTm"`
+	AccountId   string        `xml:"Acct>Id>Othr>Id"`
+	Currency    string        `xml:"Acct>Ccy"`
+	Servicer    string        `xml:"Acct>Svcr>FinInstnId>Othr>Id"`
+	Balances    []camtBalance `xml:"Bal"`
+	Entries     []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>DtTm"`
+}
+
+type camtEntry struct {
+	Reference   string     `xml:"NtryRef,omitempty"`
+	Amount      camtAmount `xml:"Amt"`
+	Indicator   string     `xml:"CdtDbtInd"`
+	Status      string     `xml:"Sts"`
+	BookingDate string     `xml:"BookgDt>DtTm"`
+	ValueDate   string     `xml:"ValDt>DtTm"`
+	ServicerRef string     `xml:"AcctSvcrRef"`
+	Code        string     `xml:"BkTxCd>Prtry>Cd"`
+	CodeIssuer  string     `xml:"BkTxCd>Prtry>Issr"`
+	Info        string     `xml:"AddtlNtryInf,omitempty"`
+}
+
+func camtTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// camtAmountOf splits a signed amount into the unsigned amount and credit/debit indicator camt expects.
+func camtAmountOf(m money.Money) (camtAmount, string) {
+	indicator := "CRDT"
+	if m.IsNegative() {
+		indicator = "DBIT"
+		m = m.Neg()
+	}
+	return camtAmount{Currency: m.Currency, Value: m.Decimal()}, indicator
+}
+
+func camtBalanceOf(code string, m money.Money, t time.Time) camtBalance {
+	amount, indicator := camtAmountOf(m)
+	return camtBalance{
+		Type:      code,
+		Amount:    amount,
+		Indicator: indicator,
+		Date:      camtTime(t),
+	}
+}
+
+func renderCAMT053(w io.Writer, s *model.Statement) error {
+	id := fmt.Sprintf("%s-%s-%s", s.AccountId, s.From.UTC().Format("20060102"), lastDay(s).Format("20060102"))
+
+	doc := camtDocument{
+		Namespace: camt053Namespace,
+		GroupHdr: camtGroupHdr{
+			MessageId:   fmt.Sprintf("%s-%d", id, s.CreatedTime.Unix()),
+			CreatedTime: camtTime(s.CreatedTime),
+		},
+		Statement: camtStatement{
+			Id:          id,
+			CreatedTime: camtTime(s.CreatedTime),
+			From:        camtTime(s.From),
+			To:          camtTime(s.To),
+			AccountId:   s.AccountId,
+			Currency:    s.Currency,
+			Servicer:    bankId,
+			Balances: []camtBalance{
+				camtBalanceOf("OPBD", s.OpeningBalance, s.From),
+				camtBalanceOf("CLBD", s.ClosingBalance, s.To),
+			},
+		},
+	}
+
+	for _, line := range s.Lines {
+		amount, indicator := camtAmountOf(line.Amount)
+		code := line.Type
+		if line.Kind != "" || code == "" {
+			code = line.Description
+		}
+		doc.Statement.Entries = append(doc.Statement.Entries, camtEntry{
+			Reference:   line.TransactionId,
+			Amount:      amount,
+			Indicator:   indicator,
+			Status:      "BOOK",
+			BookingDate: camtTime(line.BookedTime),
+			ValueDate:   camtTime(line.BookedTime),
+			ServicerRef: line.EntryId,
+			Code:        code,
+			CodeIssuer:  bankId,
+			Info:        line.Counterparty,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write camt.053 statement : %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write camt.053 statement : %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
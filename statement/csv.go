@@ -0,0 +1,38 @@
+package statement
+
+import (
+	"account-management/model"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// renderCSV writes one row per posting between an opening and a closing balance row, so that the
+// file can be re-imported as is.
+func renderCSV(w io.Writer, s *model.Statement) error {
+	out := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"date", "transaction_id", "type", "description", "counterparty", "amount", "balance", "currency"},
+		{s.From.UTC().Format(time.RFC3339), "", "", "Opening balance", "", "", s.OpeningBalance.Decimal(), s.Currency},
+	}
+	for _, line := range s.Lines {
+		rows = append(rows, []string{
+			line.BookedTime.UTC().Format(time.RFC3339),
+			line.TransactionId,
+			line.Type,
+			line.Description,
+			line.Counterparty,
+			line.Amount.Decimal(),
+			line.Balance.Decimal(),
+			s.Currency,
+		})
+	}
+	rows = append(rows, []string{s.To.UTC().Format(time.RFC3339), "", "", "Closing balance", "", "", s.ClosingBalance.Decimal(), s.Currency})
+
+	if err := out.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write csv statement : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package statement
+
+import (
+	"account-management/model"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OFX 2.2 (XML) bank statement, as imported by most accounting packages.
+
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+`
+
+type ofxDocument struct {
+	XMLName xml.Name     `xml:"OFX"`
+	SignOn  ofxSignOn    `xml:"SIGNONMSGSRSV1>SONRS"`
+	Bank    ofxStatement `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOn struct {
+	Status   ofxStatus `xml:"STATUS"`
+	DTServer string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStatement struct {
+	TrnUID   string          `xml:"TRNUID"`
+	Status   ofxStatus       `xml:"STATUS"`
+	Currency string          `xml:"STMTRS>CURDEF"`
+	Account  ofxAccount      `xml:"STMTRS>BANKACCTFROM"`
+	List     ofxTransactions `xml:"STMTRS>BANKTRANLIST"`
+	Ledger   ofxBalance      `xml:"STMTRS>LEDGERBAL"`
+}
+
+type ofxAccount struct {
+	BankId      string `xml:"BANKID"`
+	AccountId   string `xml:"ACCTID"`
+	AccountType string `xml:"ACCTTYPE"`
+}
+
+type ofxTransactions struct {
+	Start        string           `xml:"DTSTART"`
+	End          string           `xml:"DTEND"`
+	Transactions []ofxTransaction `xml:"STMTTRN"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FitId  string `xml:"FITID"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+func ofxTransactionType(line model.StatementLine) string {
+	if line.Kind == model.EntryKindFee {
+		return "FEE"
+	}
+	switch line.Type {
+	case "Deposit":
+		return "DEP"
+	case "Withdraw":
+		return "ATM"
+	case "Interest", "OverdraftInterest":
+		return "INT"
+	case "Transfer":
+		return "XFER"
+	}
+	if line.IsDebit() {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+func renderOFX(w io.Writer, s *model.Statement) error {
+	doc := ofxDocument{
+		SignOn: ofxSignOn{
+			Status:   ofxStatus{Code: 0, Severity: "INFO"},
+			DTServer: ofxTime(s.CreatedTime),
+			Language: "ENG",
+		},
+		Bank: ofxStatement{
+			TrnUID:   "0",
+			Status:   ofxStatus{Code: 0, Severity: "INFO"},
+			Currency: s.Currency,
+			Account: ofxAccount{
+				BankId:      bankId,
+				AccountId:   s.AccountId,
+				AccountType: "CHECKING",
+			},
+			List: ofxTransactions{
+				Start: ofxTime(s.From),
+				End:   ofxTime(s.To),
+			},
+			Ledger: ofxBalance{
+				Amount: s.ClosingBalance.Decimal(),
+				AsOf:   ofxTime(s.To),
+			},
+		},
+	}
+
+	for _, line := range s.Lines {
+		// FITID must be unique per account ; opening balances have no transaction id but do
+		// have a journal entry.
+		doc.Bank.List.Transactions = append(doc.Bank.List.Transactions, ofxTransaction{
+			Type:   ofxTransactionType(line),
+			Posted: ofxTime(line.BookedTime),
+			Amount: line.Amount.Decimal(),
+			FitId:  line.EntryId,
+			Name:   line.Counterparty,
+			Memo:   line.Description,
+		})
+	}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return fmt.Errorf("failed to write ofx statement : %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write ofx statement : %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,70 @@
+package statement
+
+import (
+	"account-management/model"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Supported output formats.
+const (
+	FormatCSV     = "csv"
+	FormatOFX     = "ofx"
+	FormatCAMT053 = "camt053"
+)
+
+// bankId identifies this service in OFX and camt.053 files, which expect a bank identifier.
+const bankId = "account-management"
+
+type renderer struct {
+	contentType string
+	extension   string
+	render      func(w io.Writer, s *model.Statement) error
+}
+
+var renderers = map[string]renderer{
+	FormatCSV:     {"text/csv", "csv", renderCSV},
+	FormatOFX:     {"application/x-ofx", "ofx", renderOFX},
+	FormatCAMT053: {"application/xml", "xml", renderCAMT053},
+}
+
+func lookup(format string) (renderer, error) {
+	r, ok := renderers[strings.ToLower(format)]
+	if !ok {
+		return renderer{}, fmt.Errorf("unsupported statement format : %s (use %s, %s or %s)", format, FormatCSV, FormatOFX, FormatCAMT053)
+	}
+	return r, nil
+}
+
+// Validate tells whether format is supported.
+func Validate(format string) error {
+	_, err := lookup(format)
+	return err
+}
+
+func ContentType(format string) string {
+	r, _ := lookup(format)
+	return r.contentType
+}
+
+// FileName is the conventional name of the statement file, e.g. statement-<account>-20240101-20240131.csv.
+func FileName(s *model.Statement, format string) string {
+	r, _ := lookup(format)
+	return fmt.Sprintf("statement-%s-%s-%s.%s", s.AccountId, s.From.Format("20060102"), lastDay(s).Format("20060102"), r.extension)
+}
+
+// Render writes s to w in the given format.
+func Render(w io.Writer, s *model.Statement, format string) error {
+	r, err := lookup(format)
+	if err != nil {
+		return err
+	}
+	return r.render(w, s)
+}
+
+// lastDay is the last day covered by the statement, its end being exclusive.
+func lastDay(s *model.Statement) time.Time {
+	return s.To.UTC().Add(-time.Nanosecond)
+}
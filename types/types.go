@@ -1,10 +1,22 @@
 package types
 
+import "time"
+
 type Response struct {
 	Message string
 	Status  int
 }
 
+// Principal is the authenticated caller, taken from the claims of a verified jwt-token.
+type Principal struct {
+	UserId    string
+	TokenId   string
+	SessionId string
+	Role      string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
 // type TransactionRequest struct {
 // 	Transaction model.Transaction
 // }
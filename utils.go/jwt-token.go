@@ -1,51 +1,115 @@
 package utils
 
 import (
+	"account-management/types"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
+// principalKey is the gin.Context key under which JwtAuthMiddleware stores the caller.
+const principalKey = "principal"
+
+// tokenClaims are the registered claims plus sid, the session family the token was issued for,
+// the role of the account when the token was issued and iat_ns, its issue time in nanoseconds :
+// iat is in seconds, too coarse to tell a token from a revocation of the same second.
+type tokenClaims struct {
+	SessionId    string `json:"sid,omitempty"`
+	Role         string `json:"role,omitempty"`
+	IssuedAtNano int64  `json:"iat_ns,omitempty"`
+	jwt.StandardClaims
+}
+
 var (
-	token_lifespan = 24
-	API_SECRET     = []byte("mysecret")
+	token_lifespan         = 15 * time.Minute
+	refresh_token_lifespan = 30 * 24 * time.Hour
+	token_issuer           = "account-management"
+	API_SECRET             []byte
 )
 
-func GenerateToken(username string) (string, error) {
+// SetTokenConfig sets the signing secret, lifespans and issuer of access and refresh tokens.
+func SetTokenConfig(secret string, lifespan, refreshLifespan time.Duration, issuer string) {
+	API_SECRET = []byte(secret)
+	token_lifespan = lifespan
+	refresh_token_lifespan = refreshLifespan
+	token_issuer = issuer
+}
+
+// TokenLifespan is the lifespan of the access tokens issued by GenerateToken.
+func TokenLifespan() time.Duration {
+	return token_lifespan
+}
 
-	// token_lifespan, err := strconv.Atoi(os.Getenv("TOKEN_HOUR_LIFESPAN"))
+// RefreshTokenLifespan is the lifespan of a refresh token, renewed at every rotation.
+func RefreshTokenLifespan() time.Duration {
+	return refresh_token_lifespan
+}
 
-	// if err != nil {
-	// 	return "", err
-	// }
+// GenerateToken issues a short-lived access token whose subject is the id of the logged in user
+// and whose sid is the session family it belongs to.
+func GenerateToken(userId, sessionId, role string) (string, error) {
+	now := time.Now()
 
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["username"] = 10
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(token_lifespan)).Unix()
+	claims := tokenClaims{
+		SessionId:    sessionId,
+		Role:         role,
+		IssuedAtNano: now.UnixNano(),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   userId,
+			Issuer:    token_issuer,
+			Id:        uuid.NewString(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(token_lifespan).Unix(),
+		},
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString(API_SECRET)
 
 }
 
-func TokenValid(c *gin.Context) error {
-	tokenString := ExtractToken(c)
+// ParseToken verifies the signature, expiry and issuer of tokenString and returns its principal.
+func ParseToken(tokenString string) (*types.Principal, error) {
+	var claims tokenClaims
 
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
 		return API_SECRET, nil
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if !claims.VerifyIssuer(token_issuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	if claims.Subject == "" || claims.Id == "" || claims.IssuedAt == 0 {
+		return nil, errors.New("token is missing sub, jti or iat claims")
 	}
-	return nil
+
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	if claims.IssuedAtNano != 0 {
+		issuedAt = time.Unix(0, claims.IssuedAtNano)
+	}
+
+	return &types.Principal{
+		UserId:    claims.Subject,
+		TokenId:   claims.Id,
+		SessionId: claims.SessionId,
+		Role:      claims.Role,
+		IssuedAt:  issuedAt,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
 }
 
 func ExtractToken(c *gin.Context) string {
@@ -60,25 +124,19 @@ func ExtractToken(c *gin.Context) string {
 	return ""
 }
 
-func ExtractTokenID(c *gin.Context) (uint, error) {
+func SetPrincipal(c *gin.Context, principal *types.Principal) {
+	c.Set(principalKey, principal)
+}
 
-	tokenString := ExtractToken(c)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return API_SECRET, nil
-	})
-	if err != nil {
-		return 0, err
+// CurrentPrincipal returns the caller authenticated by JwtAuthMiddleware.
+func CurrentPrincipal(c *gin.Context) (*types.Principal, error) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, errors.New("request is not authenticated")
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["username"]), 10, 32)
-		if err != nil {
-			return 0, err
-		}
-		return uint(uid), nil
+	principal, ok := value.(*types.Principal)
+	if !ok || principal.UserId == "" {
+		return nil, errors.New("request is not authenticated")
 	}
-	return 0, nil
+	return principal, nil
 }
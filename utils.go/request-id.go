@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIdHeader carries the id of a request, given by the client or generated by
+// RequestIdMiddleware, and is echoed in the response.
+const RequestIdHeader = "X-Request-Id"
+
+// requestIdKey is the gin.Context key under which RequestIdMiddleware stores the request id.
+const requestIdKey = "request_id"
+
+func SetRequestId(c *gin.Context, requestId string) {
+	c.Set(requestIdKey, requestId)
+}
+
+// RequestId returns the id of the request, blank outside RequestIdMiddleware.
+func RequestId(c *gin.Context) string {
+	return c.GetString(requestIdKey)
+}